@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// errArchiveEmpty возвращается, когда в архиве нет анекдотов
+var errArchiveEmpty = errors.New("архив анекдотов пуст")
+
+// SaveJoke сохраняет анекдот в архив; повторно полученные анекдоты игнорируются
+func (s *Storage) SaveJoke(ctx context.Context, joke Joke) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO jokes (hash, text, source, is_russian, first_seen) VALUES (?, ?, ?, ?, ?)`,
+		jokeHash(joke.Text), joke.Text, joke.Source, joke.IsRussian, time.Now().UTC())
+	return err
+}
+
+// RandomJoke возвращает случайный анекдот из архива, подходящий под фильтр
+func (s *Storage) RandomJoke(ctx context.Context, filter JokeFilter) (Joke, error) {
+	query := `SELECT text, source, is_russian FROM jokes WHERE 1 = 1`
+	var args []any
+	switch filter.Lang {
+	case langRussian:
+		query += ` AND is_russian = 1`
+	case langEnglish:
+		query += ` AND is_russian = 0`
+	}
+	if len(filter.Sources) > 0 {
+		query += ` AND source IN (?` + strings.Repeat(`, ?`, len(filter.Sources)-1) + `)`
+		for _, source := range filter.Sources {
+			args = append(args, source)
+		}
+	}
+	if filter.MaxLen > 0 {
+		query += ` AND length(text) <= ?`
+		args = append(args, filter.MaxLen)
+	}
+	query += ` ORDER BY RANDOM() LIMIT 1`
+
+	var joke Joke
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(&joke.Text, &joke.Source, &joke.IsRussian)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Joke{}, errArchiveEmpty
+	}
+	return joke, err
+}
+
+// CountJokes возвращает количество анекдотов в архиве
+func (s *Storage) CountJokes(ctx context.Context) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM jokes`).Scan(&n)
+	return n, err
+}
+
+// ArchiveProvider отдаёт случайные анекдоты из локального архива
+type ArchiveProvider struct {
+	storage *Storage
+}
+
+func NewArchiveProvider(storage *Storage) ArchiveProvider {
+	return ArchiveProvider{storage: storage}
+}
+
+func (p ArchiveProvider) Name() string {
+	return "archive"
+}
+
+func (p ArchiveProvider) FetchJoke(ctx context.Context) (Joke, error) {
+	return p.FetchFilteredJoke(ctx, JokeFilter{})
+}
+
+func (p ArchiveProvider) FetchFilteredJoke(ctx context.Context, filter JokeFilter) (Joke, error) {
+	joke, err := p.storage.RandomJoke(ctx, filter)
+	if err != nil {
+		return Joke{}, fmt.Errorf("ошибка чтения архива: %w", err)
+	}
+	return joke, nil
+}
+
+// ArchivingProvider сохраняет в архив каждый анекдот, полученный от провайдера
+type ArchivingProvider struct {
+	inner   JokeProvider
+	storage *Storage
+}
+
+func NewArchivingProvider(inner JokeProvider, storage *Storage) ArchivingProvider {
+	return ArchivingProvider{inner: inner, storage: storage}
+}
+
+func (p ArchivingProvider) Name() string {
+	return p.inner.Name()
+}
+
+func (p ArchivingProvider) FetchJoke(ctx context.Context) (Joke, error) {
+	joke, err := p.inner.FetchJoke(ctx)
+	if err != nil {
+		return joke, err
+	}
+	// Ошибка архива не должна мешать отдать анекдот
+	if err := p.storage.SaveJoke(context.WithoutCancel(ctx), joke); err != nil {
+		logger.Errorf("Ошибка сохранения анекдота от %s в архив: %v", p.Name(), err)
+	}
+	return joke, nil
+}
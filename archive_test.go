@@ -0,0 +1,79 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func openTestStorage(t *testing.T) *Storage {
+	t.Helper()
+	storage, err := OpenStorage(filepath.Join(t.TempDir(), "jokes.db"))
+	if err != nil {
+		t.Fatalf("OpenStorage error: %v", err)
+	}
+	t.Cleanup(func() { storage.Close() })
+	return storage
+}
+
+func TestArchivingProvider_StoresJokes(t *testing.T) {
+	storage := openTestStorage(t)
+	provider := NewArchivingProvider(okProvider("good"), storage)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := provider.FetchJoke(ctx); err != nil {
+			t.Fatalf("FetchJoke error: %v", err)
+		}
+	}
+	n, err := storage.CountJokes(ctx)
+	if err != nil {
+		t.Fatalf("CountJokes error: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected duplicates to be stored once, got %d jokes", n)
+	}
+}
+
+func TestArchiveProvider_FetchJoke(t *testing.T) {
+	storage := openTestStorage(t)
+	provider := NewArchiveProvider(storage)
+	ctx := context.Background()
+
+	if _, err := provider.FetchJoke(ctx); !errors.Is(err, errArchiveEmpty) {
+		t.Fatalf("expected errArchiveEmpty, got %v", err)
+	}
+	want := Joke{Text: "Архивный анекдот", Source: "baneks.ru", IsRussian: true}
+	if err := storage.SaveJoke(ctx, want); err != nil {
+		t.Fatalf("SaveJoke error: %v", err)
+	}
+	joke, err := provider.FetchJoke(ctx)
+	if err != nil {
+		t.Fatalf("FetchJoke error: %v", err)
+	}
+	if joke != want {
+		t.Errorf("expected %+v, got %+v", want, joke)
+	}
+}
+
+func TestProviderRegistry_FallsBackToArchive(t *testing.T) {
+	storage := openTestStorage(t)
+	ctx := context.Background()
+	if err := storage.SaveJoke(ctx, Joke{Text: "offline", Source: "archive"}); err != nil {
+		t.Fatalf("SaveJoke error: %v", err)
+	}
+	registry := NewProviderRegistry(ProviderSpec{Provider: failingProvider("bad"), Weight: 1})
+	registry.SetFallback(NewArchiveProvider(storage))
+
+	joke, err := registry.FetchJoke(ctx)
+	if err != nil {
+		t.Fatalf("FetchJoke error: %v", err)
+	}
+	if joke.Text != "offline" {
+		t.Errorf("expected joke from archive, got %q", joke.Text)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Параметры пакетной выдачи анекдотов
+const (
+	defaultBatchCount = 5
+	maxBatchCount     = 20
+	maxBatchRounds    = 3
+	batchTimeout      = 5 * time.Second
+)
+
+// ProviderFailure — сводка ошибок одного провайдера при пакетном запросе
+type ProviderFailure struct {
+	Provider  string `json:"provider"`
+	Count     int    `json:"count"`
+	LastError string `json:"last_error"`
+}
+
+// JokeBatch — результат пакетного запроса анекдотов
+type JokeBatch struct {
+	Jokes     []Joke            `json:"jokes"`
+	Requested int               `json:"requested"`
+	Partial   bool              `json:"partial"`
+	Errors    []ProviderFailure `json:"errors,omitempty"`
+}
+
+// FetchBatch параллельно запрашивает count разных анекдотов с общим дедлайном.
+// В каждом раунде запросы распределяются по разным провайдерам, а повторы
+// отбрасываются и дозапрашиваются, пока позволяет время.
+func (r *ProviderRegistry) FetchBatch(ctx context.Context, count int, filter JokeFilter) JokeBatch {
+	batch := JokeBatch{Jokes: []Joke{}, Requested: count}
+	if !r.Matches(filter) {
+		batch.Partial = true
+		return batch
+	}
+
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	failures := make(map[string]*ProviderFailure)
+	report := func(provider string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		f, ok := failures[provider]
+		if !ok {
+			f = &ProviderFailure{Provider: provider}
+			failures[provider] = f
+		}
+		f.Count++
+		f.LastError = err.Error()
+	}
+
+	for round := 0; round < maxBatchRounds && len(batch.Jokes) < count && ctx.Err() == nil; round++ {
+		missing := count - len(batch.Jokes)
+		claims := newProviderClaims()
+		var wg sync.WaitGroup
+		wg.Add(missing)
+		for i := 0; i < missing; i++ {
+			go func() {
+				defer wg.Done()
+				joke, err := r.fetchUpstream(ctx, filter, report, claims)
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				hash := jokeHash(joke.Text)
+				if seen[hash] || len(batch.Jokes) >= count {
+					return
+				}
+				seen[hash] = true
+				batch.Jokes = append(batch.Jokes, joke)
+			}()
+		}
+		wg.Wait()
+	}
+
+	// Отключённые выключателем провайдеры тоже попадают в сводку
+	for _, name := range r.openProviders(filter) {
+		if _, ok := failures[name]; !ok {
+			failures[name] = &ProviderFailure{Provider: name, LastError: "провайдер временно отключён"}
+		}
+	}
+	for _, f := range failures {
+		batch.Errors = append(batch.Errors, *f)
+	}
+	sort.Slice(batch.Errors, func(i, j int) bool { return batch.Errors[i].Provider < batch.Errors[j].Provider })
+	batch.Partial = len(batch.Jokes) < count
+	return batch
+}
+
+// getJokesBatch обрабатывает GET /jokes?count=N, поддерживая те же фильтры, что и /random-joke
+func getJokesBatch(w http.ResponseWriter, r *http.Request) {
+	count := defaultBatchCount
+	if raw := r.URL.Query().Get("count"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			http.Error(w, "count должен быть положительным числом", http.StatusBadRequest)
+			return
+		}
+		count = min(n, maxBatchCount)
+	}
+	filter, err := parseJokeFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !providerRegistry.Matches(filter) {
+		http.Error(w, "Нет источников, подходящих под фильтр", http.StatusNotFound)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), batchTimeout)
+	defer cancel()
+
+	batch := providerRegistry.FetchBatch(ctx, count, filter)
+	logFor(r.Context()).Infof("Пакетный запрос: получено %d из %d анекдотов", len(batch.Jokes), count)
+	for _, joke := range batch.Jokes {
+		webJokes.Remember(r.Context(), joke)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(batch.Jokes) == 0 {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	if err := json.NewEncoder(w).Encode(batch); err != nil {
+		logFor(r.Context()).Errorf("Ошибка сериализации анекдотов в JSON: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// cyclingProvider по кругу отдаёт ограниченный набор анекдотов
+type cyclingProvider struct {
+	name  string
+	texts []string
+	calls atomic.Int32
+}
+
+func (p *cyclingProvider) Name() string { return p.name }
+
+func (p *cyclingProvider) FetchJoke(ctx context.Context) (Joke, error) {
+	n := int(p.calls.Add(1)) - 1
+	return Joke{Text: p.texts[n%len(p.texts)], Source: p.name}, nil
+}
+
+// brokenProvider всегда возвращает ошибку, безопасен для параллельных вызовов
+type brokenProvider struct{ name string }
+
+func (p brokenProvider) Name() string { return p.name }
+
+func (p brokenProvider) FetchJoke(ctx context.Context) (Joke, error) {
+	return Joke{}, errors.New("upstream down")
+}
+
+func TestProviderRegistry_FetchBatchDeduplicates(t *testing.T) {
+	registry := NewProviderRegistry(ProviderSpec{
+		Provider: &cyclingProvider{name: "cycle", texts: []string{"a", "b", "c"}},
+		Weight:   1,
+	})
+	batch := registry.FetchBatch(context.Background(), 5, JokeFilter{})
+	if len(batch.Jokes) != 3 {
+		t.Fatalf("expected 3 unique jokes, got %d", len(batch.Jokes))
+	}
+	if !batch.Partial {
+		t.Error("batch with fewer jokes than requested should be partial")
+	}
+}
+
+func TestProviderRegistry_FetchBatchSpreadsAcrossProviders(t *testing.T) {
+	registry := NewProviderRegistry(
+		ProviderSpec{Provider: &cyclingProvider{name: "heavy", texts: []string{"h1", "h2", "h3", "h4"}}, Weight: 100},
+		ProviderSpec{Provider: &cyclingProvider{name: "light-a", texts: []string{"a1", "a2"}}, Weight: 1},
+		ProviderSpec{Provider: &cyclingProvider{name: "light-b", texts: []string{"b1", "b2"}}, Weight: 1},
+	)
+	batch := registry.FetchBatch(context.Background(), 3, JokeFilter{})
+	sources := make(map[string]bool)
+	for _, joke := range batch.Jokes {
+		sources[joke.Source] = true
+	}
+	if len(batch.Jokes) != 3 || len(sources) != 3 {
+		t.Errorf("expected 3 jokes from 3 providers, got %+v", batch.Jokes)
+	}
+
+	// Провайдеров меньше, чем анекдотов, — допускаются повторы
+	batch = registry.FetchBatch(context.Background(), 5, JokeFilter{})
+	if len(batch.Jokes) != 5 {
+		t.Errorf("expected 5 jokes with repeated providers, got %d", len(batch.Jokes))
+	}
+}
+
+func TestGetJokesBatchHandler(t *testing.T) {
+	saved := providerRegistry
+	defer func() { providerRegistry = saved }()
+	texts := make([]string, 30)
+	for i := range texts {
+		texts[i] = string(rune('A' + i))
+	}
+	providerRegistry = NewProviderRegistry(
+		ProviderSpec{Provider: &cyclingProvider{name: "jokeapi.dev", texts: texts}, Weight: 1},
+		ProviderSpec{Provider: brokenProvider{name: "baneks.ru"}, Weight: 1, Russian: true},
+	)
+
+	w := httptest.NewRecorder()
+	getJokesBatch(w, httptest.NewRequest("GET", "/jokes?count=100", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var batch JokeBatch
+	if err := json.NewDecoder(w.Body).Decode(&batch); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if batch.Requested != maxBatchCount || len(batch.Jokes) != maxBatchCount || batch.Partial {
+		t.Errorf("expected %d jokes, got requested=%d jokes=%d partial=%v", maxBatchCount, batch.Requested, len(batch.Jokes), batch.Partial)
+	}
+
+	w = httptest.NewRecorder()
+	getJokesBatch(w, httptest.NewRequest("GET", "/jokes?count=3&lang=ru", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 when every provider fails, got %d", w.Code)
+	}
+	batch = JokeBatch{}
+	json.NewDecoder(w.Body).Decode(&batch)
+	if len(batch.Errors) != 1 || batch.Errors[0].Provider != "baneks.ru" {
+		t.Errorf("expected error summary for baneks.ru, got %+v", batch.Errors)
+	}
+
+	w = httptest.NewRecorder()
+	getJokesBatch(w, httptest.NewRequest("GET", "/jokes?count=zero", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid count, got %d", w.Code)
+	}
+}
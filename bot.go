@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// telegramSender — часть Telegram Bot API, которой пользуется бот
+// (реализуется *tgbotapi.BotAPI)
+type telegramSender interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+}
+
+// Параметры команды /top: сообщение Telegram ограничено 4096 символами
+const (
+	topJokesInChat   = 5
+	topJokeMaxLength = 700
+
+	// botFetchTimeout — срок получения анекдота для сообщения бота вместе со всеми перезапросами
+	botFetchTimeout = 5 * time.Second
+)
+
+// JokeBot содержит логику Telegram-бота и её зависимости
+type JokeBot struct {
+	sender           telegramSender
+	fetchJoke        func(ctx context.Context) (Joke, error)
+	fetchRussianJoke func(ctx context.Context) (Joke, error)
+	fetchEnglishJoke func(ctx context.Context) (Joke, error)
+	fetchFiltered    func(ctx context.Context, filter JokeFilter) (Joke, error)
+	fetchBatch       func(ctx context.Context, count int, filter JokeFilter) JokeBatch
+	translate        func(text string) (string, error)
+	memory           *JokeMemory
+	ratings          *Ratings
+	subscriptions    *SubscriptionScheduler
+	settings         *ChatSettingsRegistry
+	sourceNames      func() []string
+	limiter          *CommandLimiter
+	// username — имя бота без @, с которым сверяются команды вида /joke@имя
+	username string
+}
+
+// NewJokeBot создаёт бота, получающего анекдоты и переводы от сервиса
+func NewJokeBot(sender telegramSender) *JokeBot {
+	var username string
+	if api, ok := sender.(*tgbotapi.BotAPI); ok {
+		username = api.Self.UserName
+	}
+	return &JokeBot{
+		sender:           sender,
+		fetchJoke:        fetchRandomJoke,
+		fetchRussianJoke: fetchRussianJoke,
+		fetchEnglishJoke: fetchEnglishJoke,
+		fetchFiltered:    fetchFilteredJoke,
+		fetchBatch: func(ctx context.Context, count int, filter JokeFilter) JokeBatch {
+			return providerRegistry.FetchBatch(ctx, count, filter)
+		},
+		translate:     translateText,
+		memory:        jokeMemory,
+		ratings:       jokeRatings,
+		subscriptions: subscriptions,
+		settings:      chatSettings,
+		sourceNames:   registrySourceNames,
+		limiter:       NewCommandLimiter(BotRateLimitConfig{}),
+		username:      username,
+	}
+}
+
+// processTelegramUpdate обрабатывает update (логика Telegram-бота)
+func (b *JokeBot) processTelegramUpdate(update tgbotapi.Update) {
+	observeTelegramUpdate(update)
+	if update.CallbackQuery != nil {
+		switch data := update.CallbackQuery.Data; {
+		case strings.HasPrefix(data, voteCallbackPrefix):
+			b.processVoteCallback(update.CallbackQuery)
+		case strings.HasPrefix(data, settingsCallbackPrefix):
+			b.processSettingsCallback(update.CallbackQuery)
+		default:
+			b.processTranslateCallback(update.CallbackQuery)
+		}
+		return
+	}
+	if update.InlineQuery != nil {
+		b.processInlineQuery(update.InlineQuery)
+		return
+	}
+	if update.MyChatMember != nil {
+		b.processMyChatMember(update.MyChatMember)
+		return
+	}
+	if update.Message == nil || !update.Message.IsCommand() || !b.addressedToBot(update.Message) {
+		return
+	}
+	group := isGroupChat(update.Message.Chat)
+	if !botCommands[update.Message.Command()] {
+		// В группах неизвестные команды могут предназначаться другим ботам
+		if !group {
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Используйте /joke для получения случайного анекдота.")
+			b.sender.Send(msg)
+		}
+		return
+	}
+	if group {
+		b = b.replyingTo(update.Message)
+	}
+	if !b.allowCommand(update.Message) {
+		return
+	}
+	switch update.Message.Command() {
+	case "start":
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Привет! Я бот-анекдотчик 🤖\n\nЯ умею присылать случайные анекдоты из разных источников. Просто отправь команду /joke, чтобы получить свежий анекдот!\n\nТакже я могу переводить анекдоты на русский язык, если потребуется.\n\nПиши /joke — и улыбка гарантирована!\n\nХочешь анекдот каждое утро? Подпишись: /subscribe 09:00\n\nЯзык, источники, автоперевод и фильтр мата: /settings")
+		b.sender.Send(msg)
+	case "joke":
+		chatID := update.Message.Chat.ID
+		settings := b.settings.Get(context.Background(), chatID)
+		msg, err := b.chatJokeMessage(chatID, b.settingsFetch(settings, b.fetchJoke), settings)
+		if errors.Is(err, errNoMatchingProviders) {
+			b.sender.Send(tgbotapi.NewMessage(chatID, "Нет источников, подходящих под настройки чата: /settings"))
+			return
+		}
+		if err != nil {
+			msg := tgbotapi.NewMessage(chatID, "Анекдоты временно недоступны")
+			b.sender.Send(msg)
+			return
+		}
+		b.sender.Send(msg)
+	case "joke_ru":
+		chatID := update.Message.Chat.ID
+		settings := b.settings.Get(context.Background(), chatID)
+		msg, err := b.chatJokeMessage(chatID, b.fetchRussianJoke, settings)
+		if err != nil {
+			msg := tgbotapi.NewMessage(chatID, "Русские анекдоты временно недоступны")
+			b.sender.Send(msg)
+			return
+		}
+		b.sender.Send(msg)
+	case "top":
+		b.sendTopJokes(update.Message.Chat.ID)
+	case "settings":
+		b.sendSettings(update.Message.Chat.ID)
+	case "subscribe":
+		b.subscribe(update.Message)
+	case "unsubscribe":
+		b.unsubscribe(update.Message)
+	case "subscriptions":
+		b.listSubscriptions(update.Message)
+	}
+}
+
+// settingsFetch возвращает функцию получения анекдота с учётом языка и источников чата;
+// без ограничений в настройках используется fetch
+func (b *JokeBot) settingsFetch(settings ChatSettings, fetch func(ctx context.Context) (Joke, error)) func(ctx context.Context) (Joke, error) {
+	filter := settings.Filter()
+	if filter.IsZero() {
+		return fetch
+	}
+	return func(ctx context.Context) (Joke, error) { return b.fetchFiltered(ctx, filter) }
+}
+
+// chatJokeMessage получает новый для чата анекдот и оформляет его по настройкам чата.
+// На уровне фильтра strict анекдот с бранью перезапрашивается, а если чистый так и
+// не нашёлся (или истекло botFetchTimeout) — маскируется.
+func (b *JokeBot) chatJokeMessage(chatID int64, fetch func(ctx context.Context) (Joke, error), settings ChatSettings) (tgbotapi.MessageConfig, error) {
+	// Один срок на все попытки, чтобы перезапросы не растягивали ответ
+	ctx, cancel := context.WithTimeout(context.Background(), botFetchTimeout)
+	defer cancel()
+	attempts := 1
+	if settings.ProfanityLevel() == profanityStrict {
+		attempts = profanityAttempts
+	}
+	var joke Joke
+	for i := 0; i < attempts; i++ {
+		next, err := recentJokes.FetchUnique(chatClientKey(chatID), func() (Joke, error) { return fetch(ctx) })
+		if err != nil {
+			if i > 0 {
+				break
+			}
+			return tgbotapi.MessageConfig{}, err
+		}
+		joke = next
+		if !containsProfanity(joke.Text) {
+			break
+		}
+	}
+	return b.jokeMessage(chatID, joke, settings), nil
+}
+
+// jokeMessage создаёт сообщение с анекдотом и кнопками оценки. Под нерусским анекдотом —
+// кнопка перевода, а при включённом автопереводе перевод сразу добавляется к тексту.
+func (b *JokeBot) jokeMessage(chatID int64, joke Joke, settings ChatSettings) tgbotapi.MessageConfig {
+	if settings.ProfanityLevel() != profanityOff {
+		joke.Text = maskProfanity(joke.Text)
+	}
+	text := joke.Text
+	translatable := !joke.IsRussian
+	if translatable && settings.AutoTranslate {
+		translation, err := b.translate(joke.Text)
+		if err != nil || translation == "" {
+			logger.Errorf("Ошибка автоперевода для чата %d: %v", chatID, err)
+		} else {
+			if settings.ProfanityLevel() != profanityOff {
+				translation = maskProfanity(translation)
+			}
+			text += "\n\n🇷🇺 " + translation
+			translatable = false
+		}
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	id := b.memory.Remember(context.Background(), joke)
+	msg.ReplyMarkup = jokeKeyboard(id, translatable)
+	return msg
+}
+
+// jokeKeyboard возвращает кнопки под анекдотом
+func jokeKeyboard(id string, translatable bool) tgbotapi.InlineKeyboardMarkup {
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("👍", voteCallbackData(id, voteUp)),
+			tgbotapi.NewInlineKeyboardButtonData("👎", voteCallbackData(id, voteDown)),
+		),
+	)
+	if translatable {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Перевести на русский", translateCallbackData(id)),
+		))
+	}
+	return keyboard
+}
+
+// processTranslateCallback переводит анекдот, под которым нажата кнопка «Перевести на русский»
+func (b *JokeBot) processTranslateCallback(query *tgbotapi.CallbackQuery) {
+	// Кнопки, отправленные до появления ID в callback_data, содержат только "translate_joke"
+	if query.Data != "translate_joke" && !strings.HasPrefix(query.Data, translateCallbackPrefix) {
+		// Неизвестная кнопка: ответ убирает индикатор загрузки у пользователя
+		b.sender.Request(tgbotapi.NewCallback(query.ID, ""))
+		return
+	}
+	if query.Message == nil {
+		b.sender.Request(tgbotapi.NewCallback(query.ID, ""))
+		return
+	}
+	id, _ := parseTranslateCallback(query.Data)
+	joke, ok := b.memory.Lookup(context.Background(), id)
+	if !ok {
+		b.sender.Request(tgbotapi.NewCallback(query.ID, "Анекдот устарел, запросите новый: /joke"))
+		return
+	}
+
+	chatID := query.Message.Chat.ID
+	callback := tgbotapi.NewCallback(query.ID, "Переведено")
+	b.sender.Request(callback)
+	// Кнопка перевода больше не нужна, кнопки оценки остаются
+	edit := tgbotapi.NewEditMessageReplyMarkup(chatID, query.Message.MessageID, jokeKeyboard(id, false))
+	b.sender.Send(edit)
+	translation, err := b.translate(joke.Text)
+	if err != nil || translation == "" {
+		msg := tgbotapi.NewMessage(chatID, "Ошибка перевода")
+		b.sender.Send(msg)
+	} else {
+		msg := tgbotapi.NewMessage(chatID, translation)
+		b.sender.Send(msg)
+	}
+}
+
+// processVoteCallback учитывает нажатие 👍/👎 под анекдотом
+func (b *JokeBot) processVoteCallback(query *tgbotapi.CallbackQuery) {
+	id, value, ok := parseVoteCallback(query.Data)
+	if !ok || query.From == nil {
+		b.sender.Request(tgbotapi.NewCallback(query.ID, ""))
+		return
+	}
+	rating, err := b.ratings.VoteByID(context.Background(), b.memory, id, telegramVoterKey(query.From.ID), value)
+	switch {
+	case errors.Is(err, errUnknownJoke):
+		b.sender.Request(tgbotapi.NewCallback(query.ID, "Анекдот устарел, запросите новый: /joke"))
+	case err != nil:
+		logger.Errorf("Ошибка сохранения оценки анекдота %s: %v", id, err)
+		b.sender.Request(tgbotapi.NewCallback(query.ID, "Не удалось сохранить оценку"))
+	default:
+		b.sender.Request(tgbotapi.NewCallback(query.ID, fmt.Sprintf("Спасибо за оценку! Рейтинг анекдота: %+d", rating.Score)))
+	}
+}
+
+// sendTopJokes отправляет лучшие анекдоты по оценкам пользователей
+func (b *JokeBot) sendTopJokes(chatID int64) {
+	top := b.ratings.Top(topJokesInChat)
+	if len(top) == 0 {
+		b.sender.Send(tgbotapi.NewMessage(chatID, "Пока нет оценённых анекдотов. Голосуйте 👍/👎 под анекдотами!"))
+		return
+	}
+	var text strings.Builder
+	text.WriteString("🏆 Лучшие анекдоты:")
+	for i, rating := range top {
+		fmt.Fprintf(&text, "\n\n%d. [%+d] %s", i+1, rating.Score, truncateText(rating.Joke.Text, topJokeMaxLength))
+	}
+	b.sender.Send(tgbotapi.NewMessage(chatID, text.String()))
+}
+
+// subscribe оформляет подписку чата: /subscribe ЧЧ:ММ [ru|en] [Nh] [часовой пояс]
+func (b *JokeBot) subscribe(message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	if b.subscriptions == nil {
+		b.sender.Send(tgbotapi.NewMessage(chatID, "Подписки временно недоступны"))
+		return
+	}
+	sub, err := parseSubscription(chatID, message.CommandArguments(), b.subscriptions.Timezone())
+	if err != nil {
+		b.sender.Send(tgbotapi.NewMessage(chatID, err.Error()))
+		return
+	}
+	if err := b.subscriptions.Subscribe(context.Background(), sub); err != nil {
+		if errors.Is(err, errTooManySubscriptions) {
+			b.sender.Send(tgbotapi.NewMessage(chatID, "Слишком много подписок. Отмените лишние: /unsubscribe ЧЧ:ММ"))
+			return
+		}
+		logger.Errorf("Ошибка сохранения подписки чата %d: %v", chatID, err)
+		b.sender.Send(tgbotapi.NewMessage(chatID, "Не удалось оформить подписку, попробуйте позже"))
+		return
+	}
+	logger.Infof("Чат %d подписался: %s", chatID, sub.Describe())
+	b.sender.Send(tgbotapi.NewMessage(chatID, "Подписка оформлена: "+sub.Describe()+".\nОтменить: /unsubscribe "+sub.At))
+}
+
+// unsubscribe отменяет подписку чата на указанное время или все подписки
+func (b *JokeBot) unsubscribe(message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	if b.subscriptions == nil {
+		b.sender.Send(tgbotapi.NewMessage(chatID, "Подписки временно недоступны"))
+		return
+	}
+	at := strings.TrimSpace(message.CommandArguments())
+	if at != "" {
+		var ok bool
+		if at, ok = parseSubscriptionTime(at); !ok {
+			b.sender.Send(tgbotapi.NewMessage(chatID, "Использование: /unsubscribe [ЧЧ:ММ]"))
+			return
+		}
+	}
+	removed, err := b.subscriptions.Unsubscribe(context.Background(), chatID, at)
+	switch {
+	case err != nil:
+		logger.Errorf("Ошибка отмены подписки чата %d: %v", chatID, err)
+		b.sender.Send(tgbotapi.NewMessage(chatID, "Не удалось отменить подписку, попробуйте позже"))
+	case removed == 0:
+		b.sender.Send(tgbotapi.NewMessage(chatID, "Подписок не найдено. Список подписок: /subscriptions"))
+	case at != "":
+		b.sender.Send(tgbotapi.NewMessage(chatID, "Подписка на "+at+" отменена"))
+	default:
+		b.sender.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Подписки отменены: %d", removed)))
+	}
+}
+
+// listSubscriptions показывает подписки чата
+func (b *JokeBot) listSubscriptions(message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	if b.subscriptions == nil {
+		b.sender.Send(tgbotapi.NewMessage(chatID, "Подписки временно недоступны"))
+		return
+	}
+	subs := b.subscriptions.List(chatID)
+	if len(subs) == 0 {
+		b.sender.Send(tgbotapi.NewMessage(chatID, "Подписок нет. Оформить: /subscribe 09:00"))
+		return
+	}
+	var text strings.Builder
+	text.WriteString("Подписки:")
+	for _, sub := range subs {
+		text.WriteString("\n• " + sub.Describe())
+	}
+	b.sender.Send(tgbotapi.NewMessage(chatID, text.String()))
+}
+
+// sendScheduledJoke отправляет анекдот по подписке; язык подписки важнее настроек чата
+func (b *JokeBot) sendScheduledJoke(sub Subscription) {
+	settings := b.settings.Get(context.Background(), sub.ChatID)
+	fetch := b.settingsFetch(settings, b.fetchJoke)
+	switch sub.Lang {
+	case langRussian:
+		fetch = b.fetchRussianJoke
+	case langEnglish:
+		fetch = b.fetchEnglishJoke
+	}
+	msg, err := b.chatJokeMessage(sub.ChatID, fetch, settings)
+	if err != nil {
+		logger.Errorf("Ошибка получения анекдота для подписки чата %d: %v", sub.ChatID, err)
+		return
+	}
+	b.sender.Send(msg)
+}
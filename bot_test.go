@@ -0,0 +1,336 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// newTestJokeBot создаёт бота, работающего с фейковым Bot API, с заглушками
+// вместо провайдеров и переводчика
+func newTestJokeBot(t *testing.T, api *fakeTelegramAPI) *JokeBot {
+	t.Helper()
+	client, err := tgbotapi.NewBotAPIWithAPIEndpoint("test-token", api.endpoint())
+	if err != nil {
+		t.Fatalf("NewBotAPIWithAPIEndpoint: %v", err)
+	}
+	bot := NewJokeBot(client)
+	bot.memory = NewJokeMemory(10, time.Hour, nil)
+	bot.ratings, _ = NewRatings(nil)
+	bot.settings = NewChatSettingsRegistry(nil)
+	bot.sourceNames = func() []string { return []string{"anekdot.ru", "jokeapi"} }
+	bot.fetchJoke = func(context.Context) (Joke, error) {
+		return Joke{Text: "Test joke", IsRussian: false}, nil
+	}
+	bot.fetchRussianJoke = func(context.Context) (Joke, error) {
+		return Joke{Text: "Русский анекдот", IsRussian: true}, nil
+	}
+	bot.translate = func(text string) (string, error) {
+		if text == "Test joke" {
+			return "Тестовый перевод", nil
+		}
+		return "", errors.New("fail")
+	}
+	return bot
+}
+
+// commandUpdate создаёт update с командой из чата chatID
+func commandUpdate(chatID int64, command string) tgbotapi.Update {
+	text := "/" + command
+	return tgbotapi.Update{
+		Message: &tgbotapi.Message{
+			Chat:     &tgbotapi.Chat{ID: chatID},
+			Text:     text,
+			Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(text)}},
+		},
+	}
+}
+
+// sentTexts возвращает тексты отправленных сообщений
+func sentTexts(api *fakeTelegramAPI) []string {
+	var texts []string
+	for _, call := range api.callsTo("sendMessage") {
+		texts = append(texts, call.Params["text"])
+	}
+	return texts
+}
+
+func TestProcessTelegramUpdate_Commands(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"start", "Привет! Я бот-анекдотчик"},
+		{"joke", "Test joke"},
+		{"joke_ru", "Русский анекдот"},
+		{"unknown", "Используйте /joke для получения случайного анекдота."},
+	}
+	for i, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			api := newFakeTelegramAPI(t)
+			newTestJokeBot(t, api).processTelegramUpdate(commandUpdate(int64(200+i), tt.command))
+			texts := sentTexts(api)
+			if len(texts) != 1 || !strings.HasPrefix(texts[0], tt.want) {
+				t.Errorf("sendMessage texts = %q, want one starting with %q", texts, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessTelegramUpdate_JokeTranslateButton(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	newTestJokeBot(t, api).processTelegramUpdate(commandUpdate(210, "joke"))
+	calls := api.callsTo("sendMessage")
+	if len(calls) != 1 || !strings.Contains(calls[0].Params["reply_markup"], `"callback_data":"`+translateCallbackData(jokeID("Test joke"))+`"`) {
+		t.Fatalf("expected joke with translate button, got %v", calls)
+	}
+}
+
+func TestProcessTelegramUpdate_JokeError(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	bot := newTestJokeBot(t, api)
+	bot.fetchJoke = func(context.Context) (Joke, error) { return Joke{}, errors.New("fail") }
+	bot.processTelegramUpdate(commandUpdate(211, "joke"))
+	if texts := sentTexts(api); len(texts) != 1 || texts[0] != "Анекдоты временно недоступны" {
+		t.Errorf("unexpected replies: %q", texts)
+	}
+}
+
+// translateUpdate создаёт нажатие кнопки перевода под сообщением messageID
+func translateUpdate(chatID int64, messageID int, data string) tgbotapi.Update {
+	return tgbotapi.Update{
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:   "cbid",
+			Data: data,
+			Message: &tgbotapi.Message{
+				Chat:      &tgbotapi.Chat{ID: chatID},
+				MessageID: messageID,
+			},
+		},
+	}
+}
+
+func TestProcessTelegramUpdate_TranslateCallback(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	bot := newTestJokeBot(t, api)
+	id := bot.memory.Remember(context.Background(), Joke{Text: "Test joke"})
+	bot.processTelegramUpdate(translateUpdate(123, 1, translateCallbackData(id)))
+
+	answers := api.callsTo("answerCallbackQuery")
+	if len(answers) != 1 || answers[0].Params["callback_query_id"] != "cbid" {
+		t.Errorf("unexpected answerCallbackQuery calls: %v", answers)
+	}
+	edits := api.callsTo("editMessageReplyMarkup")
+	if len(edits) != 1 || edits[0].Params["chat_id"] != "123" || edits[0].Params["message_id"] != "1" {
+		t.Errorf("unexpected editMessageReplyMarkup calls: %v", edits)
+	}
+	if texts := sentTexts(api); len(texts) != 1 || texts[0] != "Тестовый перевод" {
+		t.Errorf("unexpected replies: %q", texts)
+	}
+}
+
+func TestProcessTelegramUpdate_TranslateOlderJoke(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	bot := newTestJokeBot(t, api)
+	jokes := []string{"Test joke", "Another joke"}
+	bot.fetchJoke = func(context.Context) (Joke, error) {
+		joke := Joke{Text: jokes[0]}
+		jokes = jokes[1:]
+		return joke, nil
+	}
+	bot.translate = func(text string) (string, error) { return "ru:" + text, nil }
+	bot.processTelegramUpdate(commandUpdate(220, "joke"))
+	bot.processTelegramUpdate(commandUpdate(220, "joke"))
+
+	// Кнопка под первым сообщением переводит первый анекдот, а не последний
+	first := api.callsTo("sendMessage")[0].Params["reply_markup"]
+	var markup tgbotapi.InlineKeyboardMarkup
+	if err := json.Unmarshal([]byte(first), &markup); err != nil {
+		t.Fatalf("invalid reply_markup %q: %v", first, err)
+	}
+	data := *markup.InlineKeyboard[1][0].CallbackData
+	bot.processTelegramUpdate(translateUpdate(220, 1, data))
+	if texts := sentTexts(api); len(texts) != 3 || texts[2] != "ru:Test joke" {
+		t.Errorf("unexpected replies: %q", texts)
+	}
+}
+
+func TestProcessTelegramUpdate_UnknownCallback(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	newTestJokeBot(t, api).processTelegramUpdate(translateUpdate(123, 1, "stale_button"))
+
+	answers := api.callsTo("answerCallbackQuery")
+	if len(answers) != 1 || answers[0].Params["callback_query_id"] != "cbid" {
+		t.Errorf("expected unknown button to be answered, got %v", answers)
+	}
+	if len(api.callsTo("sendMessage")) != 0 {
+		t.Error("expected no messages for unknown button")
+	}
+}
+
+func TestProcessTelegramUpdate_TranslateExpired(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	newTestJokeBot(t, api).processTelegramUpdate(translateUpdate(123, 1, "translate_joke"))
+
+	answers := api.callsTo("answerCallbackQuery")
+	if len(answers) != 1 || answers[0].Params["text"] == "" {
+		t.Errorf("expected callback answer explaining the joke is gone, got %v", answers)
+	}
+	if len(api.callsTo("sendMessage")) != 0 || len(api.callsTo("editMessageReplyMarkup")) != 0 {
+		t.Error("unexpected messages for an unknown joke")
+	}
+}
+
+func TestProcessTelegramUpdate_VoteAndTop(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	bot := newTestJokeBot(t, api)
+	id := bot.memory.Remember(context.Background(), Joke{Text: "Лучший анекдот", Source: "anekdot.ru", IsRussian: true})
+
+	for _, userID := range []int64{1, 2} {
+		bot.processTelegramUpdate(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:   "vote",
+			From: &tgbotapi.User{ID: userID},
+			Data: voteCallbackData(id, voteUp),
+		}})
+	}
+	answers := api.callsTo("answerCallbackQuery")
+	if len(answers) != 2 || !strings.HasSuffix(answers[1].Params["text"], "+2") {
+		t.Errorf("unexpected vote answers: %v", answers)
+	}
+
+	bot.processTelegramUpdate(commandUpdate(230, "top"))
+	if texts := sentTexts(api); len(texts) != 1 || !strings.Contains(texts[0], "1. [+2] Лучший анекдот") {
+		t.Errorf("unexpected /top reply: %q", texts)
+	}
+}
+
+// settingsUpdate создаёт нажатие кнопки меню настроек
+func settingsUpdate(chatID int64, action string) tgbotapi.Update {
+	return translateUpdate(chatID, 5, settingsCallbackPrefix+action)
+}
+
+func TestProcessTelegramUpdate_SettingsMenu(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	bot := newTestJokeBot(t, api)
+	var filters []JokeFilter
+	bot.fetchFiltered = func(_ context.Context, filter JokeFilter) (Joke, error) {
+		filters = append(filters, filter)
+		return Joke{Text: "Анекдот по настройкам", IsRussian: true}, nil
+	}
+
+	bot.processTelegramUpdate(commandUpdate(240, "settings"))
+	calls := api.callsTo("sendMessage")
+	if len(calls) != 1 || !strings.Contains(calls[0].Params["reply_markup"], settingsCallbackPrefix+settingsActionLang) {
+		t.Fatalf("expected settings menu, got %v", calls)
+	}
+
+	bot.processTelegramUpdate(settingsUpdate(240, settingsActionLang))
+	edits := api.callsTo("editMessageText")
+	if len(edits) != 1 || edits[0].Params["message_id"] != "5" || !strings.Contains(edits[0].Params["text"], "Язык /joke: русский") {
+		t.Fatalf("unexpected editMessageText calls: %v", edits)
+	}
+	bot.processTelegramUpdate(settingsUpdate(240, settingsActionSource+"jokeapi"))
+
+	bot.processTelegramUpdate(commandUpdate(240, "joke"))
+	want := JokeFilter{Lang: langRussian, Sources: []string{"anekdot.ru"}}
+	if len(filters) != 1 || !reflect.DeepEqual(filters[0], want) {
+		t.Errorf("fetch filters = %+v, want %+v", filters, want)
+	}
+	if texts := sentTexts(api); len(texts) != 2 || texts[1] != "Анекдот по настройкам" {
+		t.Errorf("unexpected replies: %q", texts)
+	}
+	// Другие чаты настройки не затрагивают
+	bot.processTelegramUpdate(commandUpdate(241, "joke"))
+	if len(filters) != 1 {
+		t.Errorf("settings of chat 240 applied to chat 241")
+	}
+}
+
+func TestProcessTelegramUpdate_SettingsLastSource(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	bot := newTestJokeBot(t, api)
+	bot.processTelegramUpdate(settingsUpdate(250, settingsActionSource+"jokeapi"))
+	bot.processTelegramUpdate(settingsUpdate(250, settingsActionSource+"anekdot.ru"))
+
+	answers := api.callsTo("answerCallbackQuery")
+	if len(answers) != 2 || answers[1].Params["text"] != "Нужен хотя бы один источник" {
+		t.Errorf("unexpected callback answers: %v", answers)
+	}
+	if got := bot.settings.Get(context.Background(), 250).Sources; !reflect.DeepEqual(got, []string{"anekdot.ru"}) {
+		t.Errorf("sources = %v", got)
+	}
+}
+
+func TestProcessTelegramUpdate_AutoTranslate(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	bot := newTestJokeBot(t, api)
+	bot.processTelegramUpdate(settingsUpdate(260, settingsActionTranslate))
+	bot.processTelegramUpdate(commandUpdate(260, "joke"))
+
+	calls := api.callsTo("sendMessage")
+	if len(calls) != 1 || calls[0].Params["text"] != "Test joke\n\n🇷🇺 Тестовый перевод" {
+		t.Fatalf("expected translated joke, got %v", calls)
+	}
+	if strings.Contains(calls[0].Params["reply_markup"], translateCallbackPrefix) {
+		t.Error("translate button shown for an auto-translated joke")
+	}
+}
+
+func TestProcessTelegramUpdate_ProfanityFilter(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	bot := newTestJokeBot(t, api)
+	jokes := []string{"Ну ты и мудак", "Чистый анекдот", "Опять мудак", "Снова мудак", "И тут мудак"}
+	bot.fetchJoke = func(context.Context) (Joke, error) {
+		joke := Joke{Text: jokes[0], IsRussian: true}
+		jokes = jokes[1:]
+		return joke, nil
+	}
+
+	// mask → strict: анекдот с бранью перезапрашивается
+	bot.processTelegramUpdate(settingsUpdate(270, settingsActionProfanity))
+	bot.processTelegramUpdate(settingsUpdate(270, settingsActionProfanity))
+	bot.processTelegramUpdate(commandUpdate(270, "joke"))
+	// Если чистых анекдотов нет, брань маскируется
+	bot.processTelegramUpdate(commandUpdate(270, "joke"))
+
+	if texts := sentTexts(api); !reflect.DeepEqual(texts, []string{"Чистый анекдот", "И тут м****"}) {
+		t.Errorf("unexpected replies: %q", texts)
+	}
+}
+
+func TestChatJokeMessage_StrictUsesOneDeadline(t *testing.T) {
+	bot := newTestJokeBot(t, newFakeTelegramAPI(t))
+	var deadlines []time.Time
+	jokes := []string{"мудак номер 1", "мудак номер 2"}
+	fetch := func(ctx context.Context) (Joke, error) {
+		deadline, _ := ctx.Deadline()
+		deadlines = append(deadlines, deadline)
+		if len(jokes) == 0 {
+			return Joke{}, context.DeadlineExceeded
+		}
+		joke := Joke{Text: jokes[0], IsRussian: true}
+		jokes = jokes[1:]
+		return joke, nil
+	}
+	settings := ChatSettings{Profanity: profanityStrict}
+
+	msg, err := bot.chatJokeMessage(275, fetch, settings)
+	if err != nil {
+		t.Fatalf("chatJokeMessage error: %v", err)
+	}
+	if msg.Text != "м**** номер 2" {
+		t.Errorf("text = %q, want the last fetched joke masked", msg.Text)
+	}
+	if len(deadlines) != 3 || deadlines[0].IsZero() || deadlines[0] != deadlines[2] {
+		t.Errorf("deadlines = %v, want one shared deadline for all attempts", deadlines)
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// settingsCallbackPrefix — префикс callback_data кнопок меню /settings
+const settingsCallbackPrefix = "settings:"
+
+// Действия меню /settings (callback_data — settingsCallbackPrefix + действие)
+const (
+	settingsActionMain      = "main"
+	settingsActionLang      = "lang"
+	settingsActionTranslate = "translate"
+	settingsActionProfanity = "profanity"
+	settingsActionSources   = "sources"
+	settingsActionSource    = "src:" // за префиксом следует имя источника
+	settingsActionReset     = "reset"
+)
+
+// ChatSettings — настройки чата Telegram
+type ChatSettings struct {
+	// Lang — язык анекдотов /joke: ru, en или пусто (любой)
+	Lang string `json:"lang,omitempty"`
+	// Sources — включённые источники, пусто — все
+	Sources []string `json:"sources,omitempty"`
+	// AutoTranslate сразу добавляет перевод к английским анекдотам
+	AutoTranslate bool `json:"auto_translate,omitempty"`
+	// Profanity — уровень фильтра ненормативной лексики: off, mask или strict
+	Profanity string `json:"profanity,omitempty"`
+}
+
+// Filter возвращает фильтр анекдотов для /joke
+func (s ChatSettings) Filter() JokeFilter {
+	return JokeFilter{Lang: s.Lang, Sources: s.Sources}
+}
+
+// SourceEnabled сообщает, включён ли источник
+func (s ChatSettings) SourceEnabled(name string) bool {
+	if len(s.Sources) == 0 {
+		return true
+	}
+	for _, source := range s.Sources {
+		if source == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ProfanityLevel возвращает уровень фильтра (по умолчанию выключен)
+func (s ChatSettings) ProfanityLevel() string {
+	if !validProfanityLevel(s.Profanity) {
+		return profanityOff
+	}
+	return s.Profanity
+}
+
+// toggleSource включает или выключает источник; all — все доступные источники.
+// Возвращает false, если выключается последний включённый источник.
+func (s *ChatSettings) toggleSource(name string, all []string) bool {
+	var enabled []string
+	for _, source := range all {
+		if s.SourceEnabled(source) != (source == name) {
+			enabled = append(enabled, source)
+		}
+	}
+	if len(enabled) == 0 {
+		return false
+	}
+	if len(enabled) == len(all) {
+		enabled = nil
+	}
+	s.Sources = enabled
+	return true
+}
+
+// chatSettingsStore — постоянное хранилище настроек чатов
+type chatSettingsStore interface {
+	LoadChatSettings(ctx context.Context, chatID int64) (ChatSettings, bool, error)
+	SaveChatSettings(ctx context.Context, chatID int64, settings ChatSettings) error
+}
+
+// ChatSettingsRegistry хранит настройки чатов в памяти и (если задано) в хранилище
+type ChatSettingsRegistry struct {
+	store chatSettingsStore
+
+	mu    sync.Mutex
+	chats map[int64]ChatSettings
+	// locks упорядочивают изменения настроек одного чата
+	locks map[int64]*sync.Mutex
+}
+
+// NewChatSettingsRegistry создаёт реестр настроек; store может быть nil
+func NewChatSettingsRegistry(store chatSettingsStore) *ChatSettingsRegistry {
+	return &ChatSettingsRegistry{
+		store: store,
+		chats: make(map[int64]ChatSettings),
+		locks: make(map[int64]*sync.Mutex),
+	}
+}
+
+// Get возвращает настройки чата (настройки по умолчанию, если чат их не менял)
+func (r *ChatSettingsRegistry) Get(ctx context.Context, chatID int64) ChatSettings {
+	r.mu.Lock()
+	settings, ok := r.chats[chatID]
+	r.mu.Unlock()
+	if ok || r.store == nil {
+		return settings
+	}
+	settings, _, err := r.store.LoadChatSettings(ctx, chatID)
+	if err != nil {
+		logger.Errorf("Ошибка чтения настроек чата %d: %v", chatID, err)
+		return ChatSettings{}
+	}
+	r.mu.Lock()
+	r.chats[chatID] = settings
+	r.mu.Unlock()
+	return settings
+}
+
+// Update изменяет настройки чата функцией change и сохраняет их. Изменения
+// одного чата выполняются по очереди, чтобы параллельные нажатия не терялись.
+func (r *ChatSettingsRegistry) Update(ctx context.Context, chatID int64, change func(*ChatSettings) bool) (ChatSettings, bool, error) {
+	lock := r.chatLock(chatID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	settings := r.Get(ctx, chatID)
+	settings.Sources = append([]string(nil), settings.Sources...)
+	if !change(&settings) {
+		return settings, false, nil
+	}
+	if r.store != nil {
+		if err := r.store.SaveChatSettings(ctx, chatID, settings); err != nil {
+			return settings, false, err
+		}
+	}
+	r.mu.Lock()
+	r.chats[chatID] = settings
+	r.mu.Unlock()
+	return settings, true, nil
+}
+
+// chatLock возвращает мьютекс изменений настроек чата
+func (r *ChatSettingsRegistry) chatLock(chatID int64) *sync.Mutex {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	lock, ok := r.locks[chatID]
+	if !ok {
+		lock = &sync.Mutex{}
+		r.locks[chatID] = lock
+	}
+	return lock
+}
+
+// settingsText возвращает описание настроек для меню
+func settingsText(s ChatSettings) string {
+	return "⚙️ Настройки чата\n\nЯзык /joke: " + settingsLangTitle(s.Lang) +
+		"\nИсточники: " + settingsSourcesTitle(s) +
+		"\nАвтоперевод английских анекдотов: " + onOff(s.AutoTranslate) +
+		"\nФильтр мата: " + settingsProfanityTitle(s.ProfanityLevel())
+}
+
+func settingsLangTitle(lang string) string {
+	switch lang {
+	case langRussian:
+		return "русский"
+	case langEnglish:
+		return "английский"
+	}
+	return "любой"
+}
+
+func settingsSourcesTitle(s ChatSettings) string {
+	if len(s.Sources) == 0 {
+		return "все"
+	}
+	return strings.Join(s.Sources, ", ")
+}
+
+func settingsProfanityTitle(level string) string {
+	switch level {
+	case profanityMask:
+		return "маскировать"
+	case profanityStrict:
+		return "не показывать"
+	}
+	return "выключен"
+}
+
+func onOff(v bool) string {
+	if v {
+		return "вкл"
+	}
+	return "выкл"
+}
+
+// settingsKeyboard возвращает главное меню настроек
+func settingsKeyboard(s ChatSettings) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Язык: "+settingsLangTitle(s.Lang), settingsCallbackPrefix+settingsActionLang),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Источники: "+settingsSourcesTitle(s), settingsCallbackPrefix+settingsActionSources),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Автоперевод: "+onOff(s.AutoTranslate), settingsCallbackPrefix+settingsActionTranslate),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Фильтр мата: "+settingsProfanityTitle(s.ProfanityLevel()), settingsCallbackPrefix+settingsActionProfanity),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Сбросить настройки", settingsCallbackPrefix+settingsActionReset),
+		),
+	)
+}
+
+// settingsSourcesKeyboard возвращает меню выбора источников
+func settingsSourcesKeyboard(s ChatSettings, all []string) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, name := range all {
+		mark := "❌ "
+		if s.SourceEnabled(name) {
+			mark = "✅ "
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(mark+name, settingsCallbackPrefix+settingsActionSource+name),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("« Назад", settingsCallbackPrefix+settingsActionMain),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// nextLang переключает язык по кругу: любой → ru → en
+func nextLang(lang string) string {
+	switch lang {
+	case "":
+		return langRussian
+	case langRussian:
+		return langEnglish
+	}
+	return ""
+}
+
+// nextProfanityLevel переключает фильтр по кругу: выключен → маскировать → не показывать
+func nextProfanityLevel(level string) string {
+	switch level {
+	case profanityOff:
+		return profanityMask
+	case profanityMask:
+		return profanityStrict
+	}
+	return profanityOff
+}
+
+// registrySourceNames возвращает имена провайдеров реестра по алфавиту
+func registrySourceNames() []string {
+	var names []string
+	for _, stats := range providerRegistry.Stats() {
+		names = append(names, stats.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sendSettings отправляет меню настроек чата
+func (b *JokeBot) sendSettings(chatID int64) {
+	settings := b.settings.Get(context.Background(), chatID)
+	msg := tgbotapi.NewMessage(chatID, settingsText(settings))
+	msg.ReplyMarkup = settingsKeyboard(settings)
+	b.sender.Send(msg)
+}
+
+// processSettingsCallback обрабатывает нажатия кнопок меню /settings
+func (b *JokeBot) processSettingsCallback(query *tgbotapi.CallbackQuery) {
+	if query.Message == nil {
+		b.sender.Request(tgbotapi.NewCallback(query.ID, ""))
+		return
+	}
+	chatID := query.Message.Chat.ID
+	action := strings.TrimPrefix(query.Data, settingsCallbackPrefix)
+	sources := b.sourceNames()
+	ctx := context.Background()
+
+	showSources := action == settingsActionSources || strings.HasPrefix(action, settingsActionSource)
+	settings, changed, err := b.settings.Update(ctx, chatID, func(s *ChatSettings) bool {
+		switch {
+		case action == settingsActionLang:
+			s.Lang = nextLang(s.Lang)
+		case action == settingsActionTranslate:
+			s.AutoTranslate = !s.AutoTranslate
+		case action == settingsActionProfanity:
+			s.Profanity = nextProfanityLevel(s.ProfanityLevel())
+		case action == settingsActionReset:
+			*s = ChatSettings{}
+		case strings.HasPrefix(action, settingsActionSource):
+			return s.toggleSource(strings.TrimPrefix(action, settingsActionSource), sources)
+		default:
+			return false
+		}
+		return true
+	})
+	switch {
+	case err != nil:
+		logger.Errorf("Ошибка сохранения настроек чата %d: %v", chatID, err)
+		b.sender.Request(tgbotapi.NewCallback(query.ID, "Не удалось сохранить настройки"))
+		return
+	case !changed && strings.HasPrefix(action, settingsActionSource):
+		b.sender.Request(tgbotapi.NewCallback(query.ID, "Нужен хотя бы один источник"))
+		return
+	}
+	b.sender.Request(tgbotapi.NewCallback(query.ID, ""))
+
+	keyboard := settingsKeyboard(settings)
+	if showSources {
+		keyboard = settingsSourcesKeyboard(settings, sources)
+	}
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, query.Message.MessageID, settingsText(settings), keyboard)
+	b.sender.Send(edit)
+}
+
+// LoadChatSettings возвращает сохранённые настройки чата
+func (s *Storage) LoadChatSettings(ctx context.Context, chatID int64) (ChatSettings, bool, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, `SELECT settings FROM chat_settings WHERE chat_id = ?`, chatID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ChatSettings{}, false, nil
+	}
+	if err != nil {
+		return ChatSettings{}, false, err
+	}
+	var settings ChatSettings
+	if err := json.Unmarshal([]byte(data), &settings); err != nil {
+		return ChatSettings{}, false, fmt.Errorf("повреждены настройки чата %d: %w", chatID, err)
+	}
+	return settings, true, nil
+}
+
+// SaveChatSettings сохраняет настройки чата
+func (s *Storage) SaveChatSettings(ctx context.Context, chatID int64, settings ChatSettings) error {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO chat_settings (chat_id, settings, updated_at) VALUES (?, ?, ?)`,
+		chatID, string(data), time.Now().Unix())
+	return err
+}
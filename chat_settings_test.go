@@ -0,0 +1,95 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestChatSettings_ToggleSource(t *testing.T) {
+	all := []string{"anekdot.ru", "jokeapi", "rzhunemogu"}
+	var s ChatSettings
+
+	if !s.toggleSource("jokeapi", all) || !reflect.DeepEqual(s.Sources, []string{"anekdot.ru", "rzhunemogu"}) {
+		t.Fatalf("after disabling jokeapi: %v", s.Sources)
+	}
+	if !s.toggleSource("anekdot.ru", all) || !s.SourceEnabled("rzhunemogu") || s.SourceEnabled("anekdot.ru") {
+		t.Fatalf("after disabling anekdot.ru: %v", s.Sources)
+	}
+	// Последний источник выключить нельзя
+	if s.toggleSource("rzhunemogu", all) || !reflect.DeepEqual(s.Sources, []string{"rzhunemogu"}) {
+		t.Fatalf("last source was disabled: %v", s.Sources)
+	}
+	// Когда включены все источники, список снова пуст
+	s.toggleSource("anekdot.ru", all)
+	s.toggleSource("jokeapi", all)
+	if s.Sources != nil {
+		t.Errorf("all sources enabled, got %v", s.Sources)
+	}
+}
+
+func TestChatSettingsRegistry_Persists(t *testing.T) {
+	storage := openTestStorage(t)
+	ctx := context.Background()
+	registry := NewChatSettingsRegistry(storage)
+
+	if got := registry.Get(ctx, 42); !reflect.DeepEqual(got, ChatSettings{}) {
+		t.Fatalf("default settings = %+v", got)
+	}
+	want := ChatSettings{Lang: langEnglish, Sources: []string{"jokeapi"}, AutoTranslate: true, Profanity: profanityStrict}
+	if _, changed, err := registry.Update(ctx, 42, func(s *ChatSettings) bool {
+		*s = want
+		return true
+	}); err != nil || !changed {
+		t.Fatalf("Update = %v, %v", changed, err)
+	}
+
+	// Настройки переживают перезапуск
+	if got := NewChatSettingsRegistry(storage).Get(ctx, 42); !reflect.DeepEqual(got, want) {
+		t.Errorf("reloaded settings = %+v, want %+v", got, want)
+	}
+	if got := NewChatSettingsRegistry(storage).Get(ctx, 43); !reflect.DeepEqual(got, ChatSettings{}) {
+		t.Errorf("other chat settings = %+v", got)
+	}
+}
+
+func TestChatSettingsRegistry_ConcurrentUpdates(t *testing.T) {
+	storage := openTestStorage(t)
+	ctx := context.Background()
+	registry := NewChatSettingsRegistry(storage)
+	all := []string{"anekdot.ru", "baneks.ru", "jokeapi.dev", "rzhunemogu.ru"}
+
+	// Параллельные нажатия на разные источники не затирают друг друга
+	var wg sync.WaitGroup
+	for _, name := range all[:3] {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if _, _, err := registry.Update(ctx, 42, func(s *ChatSettings) bool { return s.toggleSource(name, all) }); err != nil {
+				t.Errorf("Update(%s): %v", name, err)
+			}
+		}(name)
+	}
+	wg.Wait()
+
+	want := []string{"rzhunemogu.ru"}
+	if got := registry.Get(ctx, 42).Sources; !reflect.DeepEqual(got, want) {
+		t.Errorf("in-memory sources = %v, want %v", got, want)
+	}
+	if got := NewChatSettingsRegistry(storage).Get(ctx, 42).Sources; !reflect.DeepEqual(got, want) {
+		t.Errorf("stored sources = %v, want %v", got, want)
+	}
+}
+
+func TestChatSettings_ProfanityLevel(t *testing.T) {
+	if level := (ChatSettings{Profanity: "bogus"}).ProfanityLevel(); level != profanityOff {
+		t.Errorf("unknown level = %q, want off", level)
+	}
+	if level := (ChatSettings{Profanity: profanityMask}).ProfanityLevel(); level != profanityMask {
+		t.Errorf("mask level = %q", level)
+	}
+}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"sync/atomic"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
@@ -19,23 +23,36 @@ var (
 	// Глобальная конфигурация
 	appConfig *Config
 
-	// Все доступные провайдеры анекдотов
-	jokeProviders = []JokeProvider{
-		RzhunemoguProvider{}, // Русский источник
-		AnekdotRuProvider{},  // Русский источник
-		BaneksProvider{},     // Русский источник
-		DadJokeProvider{},    // Английский источник
-		JokeAPIProvider{},    // Английский источник
+	// Провайдеры по умолчанию, если в config.yaml нет секции providers
+	// (русские источники имеют больший вес)
+	defaultProviderConfigs = []ProviderConfig{
+		{Name: "rzhunemogu.ru", Weight: 3},      // Русский источник
+		{Name: "anekdot.ru", Weight: 3},         // Русский источник
+		{Name: "baneks.ru", Weight: 3},          // Русский источник
+		{Name: "icanhazdadjoke.com", Weight: 1}, // Английский источник
+		{Name: "jokeapi.dev", Weight: 1},        // Английский источник
 	}
 
-	// Веса для источников (русские источники имеют больший вес)
-	providerWeights = []int{
-		3, // RzhunemoguProvider
-		3, // AnekdotRuProvider
-		3, // BaneksProvider
-		1, // DadJokeProvider
-		1, // JokeAPIProvider
-	}
+	// Реестр провайдеров со статистикой и автоматическим отключением
+	providerRegistry = mustNewProviderRegistry(defaultProviderConfigs)
+
+	// Недавно показанные анекдоты для подавления повторов
+	recentJokes = NewRecentJokes(defaultDedupWindow, defaultDedupAttempts)
+
+	// Хаб потоков анекдотов (SSE): один запрос к провайдерам на всех подписчиков
+	jokeHub = NewJokeStreamHub(func(ctx context.Context, filter JokeFilter) (Joke, error) {
+		joke, err := providerRegistry.FetchFilteredJoke(ctx, filter)
+		if err == nil {
+			webJokes.Remember(ctx, joke)
+		}
+		return joke, err
+	})
+
+	// Общий HTTP-клиент провайдеров и переводчиков, настраивается секцией http_client
+	upstreamClient = mustNewHTTPClient(HTTPClientConfig{})
+
+	// Переводчик анекдотов (по умолчанию Google Translate proxy)
+	translator Translator = GoogleTranslator{}
 
 	// Разрешенные CORS origins
 	allowedOrigins = []string{
@@ -44,12 +61,54 @@ var (
 		"https://welcome-cattle-regular.ngrok-free.app",
 	}
 
-	// Память для хранения последних анекдотов в Telegram
-	jokeMemory map[int64]string
+	// Telegram-бот, создаётся один раз при запуске
+	jokeBot atomic.Pointer[JokeBot]
+
+	// Анекдоты, отправленные ботом с кнопкой перевода
+	jokeMemory = NewJokeMemory(defaultJokeMemorySize, defaultJokeMemoryTTL, nil)
+
+	// Анекдоты, выданные через веб-API, чтобы за них можно было проголосовать.
+	// Хранятся только в памяти: обработчики не ждут записи в SQLite, а память бота
+	// остаётся занята лишь его сообщениями.
+	webJokes = NewJokeMemory(defaultJokeMemorySize, defaultJokeMemoryTTL, nil)
+
+	// Оценки анекдотов (👍/👎), влияют на веса провайдеров
+	jokeRatings, _ = NewRatings(nil)
+
+	// Подписки на анекдоты по расписанию, создаются вместе с Telegram-ботом
+	subscriptions *SubscriptionScheduler
+
+	// Настройки чатов Telegram (/settings)
+	chatSettings = NewChatSettingsRegistry(nil)
 )
 
 type Config struct {
-	TelegramBotToken string `yaml:"telegram_bot_token"`
+	TelegramBotToken string            `yaml:"telegram_bot_token"`
+	Telegram         TelegramConfig    `yaml:"telegram"`
+	Providers        []ProviderConfig  `yaml:"providers"`
+	Prefetch         PrefetchConfig    `yaml:"prefetch"`
+	Storage          StorageConfig     `yaml:"storage"`
+	Dedup            DedupConfig       `yaml:"dedup"`
+	Translation      TranslationConfig `yaml:"translation"`
+	Health           HealthConfig      `yaml:"health"`
+	Log              LogConfig         `yaml:"log"`
+	Tracing          TracingConfig     `yaml:"tracing"`
+	HTTPClient       HTTPClientConfig  `yaml:"http_client"`
+}
+
+// ProviderConfig описывает настройки одного провайдера анекдотов
+type ProviderConfig struct {
+	Name      string        `yaml:"name"`
+	Enabled   *bool         `yaml:"enabled"`
+	Weight    int           `yaml:"weight"`
+	Timeout   time.Duration `yaml:"timeout"`
+	BaseURL   string        `yaml:"base_url"`
+	UserAgent string        `yaml:"user_agent"`
+}
+
+// IsEnabled сообщает, включён ли провайдер (по умолчанию включён)
+func (p ProviderConfig) IsEnabled() bool {
+	return p.Enabled == nil || *p.Enabled
 }
 
 func LoadConfig(filename string) (*Config, error) {
@@ -63,5 +122,77 @@ func LoadConfig(filename string) (*Config, error) {
 		return nil, err
 	}
 
+	if len(config.Providers) == 0 {
+		config.Providers = defaultProviderConfigs
+	}
+	if err := validateProviderConfigs(config.Providers); err != nil {
+		return nil, fmt.Errorf("некорректная секция providers: %w", err)
+	}
+
+	if err := config.Telegram.validate(); err != nil {
+		return nil, err
+	}
+	if config.TelegramBotToken != "" {
+		if err := config.Telegram.ensureWebhookSecret(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := newTranslatorFromConfig(config.Translation, nil); err != nil {
+		return nil, err
+	}
+	if config.Dedup.Window != nil && *config.Dedup.Window < 0 {
+		return nil, fmt.Errorf("dedup.window не может быть отрицательным")
+	}
+	if config.Dedup.Attempts < 0 {
+		return nil, fmt.Errorf("dedup.attempts не может быть отрицательным")
+	}
+	if err := config.Health.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Log.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Tracing.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.HTTPClient.validate(); err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
+
+// validateProviderConfigs проверяет имена, веса и таймауты провайдеров
+func validateProviderConfigs(providers []ProviderConfig) error {
+	seen := make(map[string]bool)
+	totalWeight := 0
+	for i, p := range providers {
+		if p.Name == "" {
+			return fmt.Errorf("providers[%d]: не указано имя провайдера", i)
+		}
+		if _, ok := providerCatalog[p.Name]; !ok {
+			return fmt.Errorf("providers[%d]: неизвестный провайдер %q", i, p.Name)
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("providers[%d]: провайдер %q указан повторно", i, p.Name)
+		}
+		seen[p.Name] = true
+		if p.Weight < 0 {
+			return fmt.Errorf("providers[%d]: отрицательный вес %d у провайдера %q", i, p.Weight, p.Name)
+		}
+		if p.Timeout < 0 {
+			return fmt.Errorf("providers[%d]: отрицательный таймаут у провайдера %q", i, p.Name)
+		}
+		if !p.IsEnabled() {
+			continue
+		}
+		if p.Weight == 0 {
+			return fmt.Errorf("providers[%d]: провайдер %q включён, но его вес равен 0", i, p.Name)
+		}
+		totalWeight += p.Weight
+	}
+	if totalWeight == 0 {
+		return fmt.Errorf("суммарный вес включённых провайдеров равен 0")
+	}
+	return nil
+}
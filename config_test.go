@@ -0,0 +1,87 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfig_DefaultProviders(t *testing.T) {
+	config, err := LoadConfig(writeConfig(t, "telegram_bot_token: test\ntelegram:\n  mode: polling\n"))
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+	if len(config.Providers) != len(defaultProviderConfigs) {
+		t.Fatalf("expected %d default providers, got %d", len(defaultProviderConfigs), len(config.Providers))
+	}
+}
+
+func TestLoadConfig_WebhookNeedsSecret(t *testing.T) {
+	if _, err := LoadConfig(writeConfig(t, "telegram_bot_token: test\n")); err == nil {
+		t.Error("expected error for webhook mode without webhook_secret and webhook_url")
+	}
+	config, err := LoadConfig(writeConfig(t, "telegram_bot_token: test\ntelegram:\n  webhook_url: https://example.com/hook\n"))
+	if err != nil || config.Telegram.WebhookSecret == "" {
+		t.Errorf("expected generated webhook secret, got %q, %v", config.Telegram.WebhookSecret, err)
+	}
+}
+
+func TestLoadConfig_Providers(t *testing.T) {
+	config, err := LoadConfig(writeConfig(t, `
+providers:
+  - name: rzhunemogu.ru
+    weight: 5
+    timeout: 2s
+    base_url: http://localhost:8081
+    user_agent: test-agent
+  - name: jokeapi.dev
+    enabled: false
+`))
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+	p := config.Providers[0]
+	if p.Weight != 5 || p.Timeout != 2*time.Second || p.BaseURL != "http://localhost:8081" || p.UserAgent != "test-agent" {
+		t.Errorf("unexpected provider config: %+v", p)
+	}
+	if config.Providers[1].IsEnabled() {
+		t.Error("jokeapi.dev should be disabled")
+	}
+	registry, err := newProviderRegistryFromConfig(config.Providers, nil)
+	if err != nil {
+		t.Fatalf("newProviderRegistryFromConfig error: %v", err)
+	}
+	if stats := registry.Stats(); len(stats) != 1 || stats[0].Name != "rzhunemogu.ru" {
+		t.Errorf("expected only rzhunemogu.ru in registry, got %+v", stats)
+	}
+}
+
+func TestLoadConfig_InvalidProviders(t *testing.T) {
+	cases := map[string]string{
+		"неизвестный провайдер": "providers:\n  - name: example.com\n    weight: 1\n",
+		"указан повторно":       "providers:\n  - name: baneks.ru\n    weight: 1\n  - name: baneks.ru\n    weight: 2\n",
+		"вес равен 0":           "providers:\n  - name: baneks.ru\n",
+		"суммарный вес":         "providers:\n  - name: baneks.ru\n    enabled: false\n",
+		"не указано имя":        "providers:\n  - weight: 1\n",
+	}
+	for want, content := range cases {
+		_, err := LoadConfig(writeConfig(t, content))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error containing %q, got %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Параметры подавления повторов по умолчанию
+const (
+	defaultDedupWindow   = 50
+	defaultDedupAttempts = 3
+	maxDedupClients      = 10000
+	dedupClientTTL       = 24 * time.Hour
+
+	sessionCookieName = "joke_session"
+	sessionCookieTTL  = 30 * 24 * time.Hour
+)
+
+// DedupConfig описывает подавление повторов анекдотов
+type DedupConfig struct {
+	// Window — сколько последних анекдотов помнить для каждого чата/сессии, 0 — отключено
+	Window *int `yaml:"window"`
+	// Attempts — сколько раз запрашивать анекдот, если пришёл повтор
+	Attempts int `yaml:"attempts"`
+}
+
+// normalizeJokeText приводит текст к виду, не зависящему от регистра,
+// пунктуации и пробелов, чтобы одинаковые анекдоты с разных сайтов совпадали
+func normalizeJokeText(text string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case r == 'ё':
+			r = 'е'
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+		default:
+			space = b.Len() > 0
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// jokeHash возвращает хэш нормализованного текста анекдота
+func jokeHash(text string) string {
+	sum := sha256.Sum256([]byte(normalizeJokeText(text)))
+	return hex.EncodeToString(sum[:])
+}
+
+// recentWindow — кольцевой буфер недавно показанных клиенту хэшей
+type recentWindow struct {
+	hashes   []string
+	next     int
+	lastSeen time.Time
+}
+
+func (w *recentWindow) contains(hash string) bool {
+	for _, h := range w.hashes {
+		if h == hash {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *recentWindow) add(hash string, size int) {
+	if len(w.hashes) < size {
+		w.hashes = append(w.hashes, hash)
+		return
+	}
+	w.hashes[w.next] = hash
+	w.next = (w.next + 1) % size
+}
+
+// RecentJokes помнит недавно показанные анекдоты для каждого клиента
+// (чат Telegram или веб-сессия) и помогает избегать повторов
+type RecentJokes struct {
+	window   int
+	attempts int
+
+	mu      sync.Mutex
+	clients map[string]*recentWindow
+}
+
+// NewRecentJokes создаёт хранилище; window <= 0 отключает подавление повторов
+func NewRecentJokes(window, attempts int) *RecentJokes {
+	if attempts <= 0 {
+		attempts = defaultDedupAttempts
+	}
+	return &RecentJokes{
+		window:   window,
+		attempts: attempts,
+		clients:  make(map[string]*recentWindow),
+	}
+}
+
+// newRecentJokesFromConfig создаёт хранилище по настройкам, подставляя значения по умолчанию
+func newRecentJokesFromConfig(cfg DedupConfig) *RecentJokes {
+	window := defaultDedupWindow
+	if cfg.Window != nil {
+		window = *cfg.Window
+	}
+	return NewRecentJokes(window, cfg.Attempts)
+}
+
+// Seen сообщает, показывали ли клиенту этот анекдот недавно
+func (r *RecentJokes) Seen(client string, joke Joke) bool {
+	if r.window <= 0 {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w, ok := r.clients[client]
+	return ok && w.contains(jokeHash(joke.Text))
+}
+
+// Remember запоминает показанный клиенту анекдот
+func (r *RecentJokes) Remember(client string, joke Joke) {
+	if r.window <= 0 {
+		return
+	}
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w, ok := r.clients[client]
+	if !ok {
+		if len(r.clients) >= maxDedupClients {
+			r.evict(now)
+		}
+		w = &recentWindow{}
+		r.clients[client] = w
+	}
+	w.add(jokeHash(joke.Text), r.window)
+	w.lastSeen = now
+}
+
+// evict удаляет неактивных клиентов, а если таких нет — самого давнего
+func (r *RecentJokes) evict(now time.Time) {
+	var oldest string
+	for client, w := range r.clients {
+		if now.Sub(w.lastSeen) > dedupClientTTL {
+			delete(r.clients, client)
+			continue
+		}
+		if oldest == "" || w.lastSeen.Before(r.clients[oldest].lastSeen) {
+			oldest = client
+		}
+	}
+	if len(r.clients) >= maxDedupClients && oldest != "" {
+		delete(r.clients, oldest)
+	}
+}
+
+// FetchUnique запрашивает анекдот, повторяя запрос (не более attempts раз),
+// если клиенту недавно уже показывали такой же. Если свежий анекдот найти
+// не удалось, возвращается последний полученный повтор.
+func (r *RecentJokes) FetchUnique(client string, fetch func() (Joke, error)) (Joke, error) {
+	var duplicate *Joke
+	for attempt := 0; attempt < r.attempts; attempt++ {
+		joke, err := fetch()
+		if err != nil {
+			if duplicate != nil {
+				break
+			}
+			return Joke{}, err
+		}
+		if !r.Seen(client, joke) {
+			r.Remember(client, joke)
+			return joke, nil
+		}
+		logger.Debugf("Повтор анекдота от %s для %s, попытка %d", joke.Source, client, attempt+1)
+		duplicate = &joke
+	}
+	r.Remember(client, *duplicate)
+	return *duplicate, nil
+}
+
+// chatClientKey возвращает ключ клиента для чата Telegram
+func chatClientKey(chatID int64) string {
+	return "tg:" + strconv.FormatInt(chatID, 10)
+}
+
+// sessionClientKey возвращает ключ клиента для веб-сессии, выдавая cookie новым посетителям
+func sessionClientKey(w http.ResponseWriter, r *http.Request) string {
+	if c, err := r.Cookie(sessionCookieName); err == nil && c.Value != "" {
+		return "web:" + c.Value
+	}
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		logFor(r.Context()).Errorf("Ошибка генерации идентификатора сессии: %v", err)
+		return "web:anonymous"
+	}
+	id := hex.EncodeToString(buf)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(sessionCookieTTL.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return "web:" + id
+}
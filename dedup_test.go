@@ -0,0 +1,92 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNormalizeJokeText(t *testing.T) {
+	a := normalizeJokeText("  Штирлиц   шёл по лесу...\r\nВдруг!  ")
+	b := normalizeJokeText("штирлиц шел по лесу, вдруг")
+	if a != b {
+		t.Errorf("expected equal normalized texts, got %q and %q", a, b)
+	}
+	if jokeHash("Knock, knock!") != jokeHash("knock knock") {
+		t.Error("expected equal hashes for texts differing in case and punctuation")
+	}
+}
+
+func TestRecentJokes_FetchUniqueSkipsDuplicates(t *testing.T) {
+	recent := NewRecentJokes(10, 3)
+	jokes := []string{"first", "First!", "second"}
+	calls := 0
+	fetch := func() (Joke, error) {
+		joke := Joke{Text: jokes[calls%len(jokes)]}
+		calls++
+		return joke, nil
+	}
+
+	if joke, _ := recent.FetchUnique("tg:1", fetch); joke.Text != "first" {
+		t.Fatalf("expected first joke, got %q", joke.Text)
+	}
+	if joke, _ := recent.FetchUnique("tg:1", fetch); joke.Text != "second" {
+		t.Fatalf("expected duplicate to be skipped, got %q", joke.Text)
+	}
+	// Другой чат видит анекдоты независимо
+	calls = 0
+	if joke, _ := recent.FetchUnique("tg:2", fetch); joke.Text != "first" {
+		t.Fatalf("expected first joke for another chat, got %q", joke.Text)
+	}
+}
+
+func TestRecentJokes_FetchUniqueReturnsDuplicateWhenExhausted(t *testing.T) {
+	recent := NewRecentJokes(10, 2)
+	same := func() (Joke, error) { return Joke{Text: "same"}, nil }
+	recent.FetchUnique("web:a", same)
+	joke, err := recent.FetchUnique("web:a", same)
+	if err != nil || joke.Text != "same" {
+		t.Fatalf("expected duplicate after exhausted attempts, got %q, %v", joke.Text, err)
+	}
+
+	failing := func() (Joke, error) { return Joke{}, errors.New("down") }
+	if _, err := recent.FetchUnique("web:a", failing); err == nil {
+		t.Error("expected error when fetch fails")
+	}
+}
+
+func TestRecentJokes_WindowSize(t *testing.T) {
+	recent := NewRecentJokes(2, 1)
+	for _, text := range []string{"a", "b", "c"} {
+		recent.Remember("tg:1", Joke{Text: text})
+	}
+	if recent.Seen("tg:1", Joke{Text: "a"}) {
+		t.Error("oldest joke should have left the window")
+	}
+	if !recent.Seen("tg:1", Joke{Text: "c"}) {
+		t.Error("latest joke should be remembered")
+	}
+}
+
+func TestSessionClientKey(t *testing.T) {
+	w := httptest.NewRecorder()
+	key := sessionClientKey(w, httptest.NewRequest("GET", "/random-joke", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != sessionCookieName {
+		t.Fatalf("expected session cookie to be set, got %v", cookies)
+	}
+
+	req := httptest.NewRequest("GET", "/random-joke", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: cookies[0].Value})
+	w = httptest.NewRecorder()
+	if again := sessionClientKey(w, req); again != key {
+		t.Errorf("expected same key for returning visitor, got %q and %q", key, again)
+	}
+	if len(w.Result().Cookies()) != 0 {
+		t.Error("cookie should not be reissued")
+	}
+}
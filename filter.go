@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"unicode/utf8"
+)
+
+// errNoMatchingProviders возвращается, когда фильтру не соответствует ни один провайдер
+var errNoMatchingProviders = errors.New("нет провайдеров, подходящих под фильтр")
+
+// Языки анекдотов для фильтра
+const (
+	langRussian = "ru"
+	langEnglish = "en"
+)
+
+// JokeFilter ограничивает выбор провайдеров и получаемые анекдоты
+type JokeFilter struct {
+	Lang    string   // "ru", "en" или пусто — любой язык
+	Sources []string // имена провайдеров (Joke.Source), пусто — любые
+	MaxLen  int      // максимальная длина анекдота в символах, 0 — без ограничения
+}
+
+// IsZero сообщает, что фильтр ничего не ограничивает
+func (f JokeFilter) IsZero() bool {
+	return f.Lang == "" && len(f.Sources) == 0 && f.MaxLen == 0
+}
+
+// MatchesProvider проверяет язык и имя провайдера до запроса анекдота
+func (f JokeFilter) MatchesProvider(name string, russian bool) bool {
+	if f.Lang == langRussian && !russian || f.Lang == langEnglish && russian {
+		return false
+	}
+	if len(f.Sources) == 0 {
+		return true
+	}
+	for _, s := range f.Sources {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches проверяет уже полученный анекдот
+func (f JokeFilter) Matches(joke Joke) bool {
+	if !f.MatchesProvider(joke.Source, joke.IsRussian) {
+		return false
+	}
+	return f.MaxLen == 0 || utf8.RuneCountInString(joke.Text) <= f.MaxLen
+}
+
+// FilteredProvider — провайдер, умеющий сам учитывать фильтр (например, архив)
+type FilteredProvider interface {
+	FetchFilteredJoke(ctx context.Context, filter JokeFilter) (Joke, error)
+}
+
+// parseJokeFilter разбирает параметры lang, source и max_len из строки запроса
+func parseJokeFilter(query url.Values) (JokeFilter, error) {
+	var f JokeFilter
+	switch lang := query.Get("lang"); lang {
+	case "", langRussian, langEnglish:
+		f.Lang = lang
+	default:
+		return f, fmt.Errorf("неизвестный язык %q, допустимы ru и en", lang)
+	}
+	for _, source := range query["source"] {
+		if _, ok := providerCatalog[source]; !ok {
+			return f, fmt.Errorf("неизвестный источник %q", source)
+		}
+		f.Sources = append(f.Sources, source)
+	}
+	if raw := query.Get("max_len"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return f, fmt.Errorf("max_len должен быть положительным числом")
+		}
+		f.MaxLen = n
+	}
+	return f, nil
+}
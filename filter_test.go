@@ -0,0 +1,111 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestParseJokeFilter(t *testing.T) {
+	filter, err := parseJokeFilter(url.Values{
+		"lang":    {"ru"},
+		"source":  {"baneks.ru", "anekdot.ru"},
+		"max_len": {"120"},
+	})
+	if err != nil {
+		t.Fatalf("parseJokeFilter error: %v", err)
+	}
+	if filter.Lang != "ru" || len(filter.Sources) != 2 || filter.MaxLen != 120 {
+		t.Errorf("unexpected filter: %+v", filter)
+	}
+
+	for _, query := range []url.Values{
+		{"lang": {"de"}},
+		{"source": {"example.com"}},
+		{"max_len": {"-1"}},
+		{"max_len": {"abc"}},
+	} {
+		if _, err := parseJokeFilter(query); err == nil {
+			t.Errorf("expected error for %v", query)
+		}
+	}
+}
+
+func TestProviderRegistry_FetchFilteredJoke(t *testing.T) {
+	ru := &stubProvider{name: "baneks.ru", fetch: func() (Joke, error) {
+		return Joke{Text: "Русский анекдот", Source: "baneks.ru", IsRussian: true}, nil
+	}}
+	en := okProvider("jokeapi.dev")
+	registry := NewProviderRegistry(
+		ProviderSpec{Provider: ru, Weight: 1, Russian: true},
+		ProviderSpec{Provider: en, Weight: 1000},
+	)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		joke, err := registry.FetchFilteredJoke(ctx, JokeFilter{Lang: "ru"})
+		if err != nil || joke.Source != "baneks.ru" {
+			t.Fatalf("expected russian joke, got %q, %v", joke.Source, err)
+		}
+	}
+	if en.calls != 0 {
+		t.Errorf("english provider should not be asked for russian jokes, got %d calls", en.calls)
+	}
+
+	if _, err := registry.FetchFilteredJoke(ctx, JokeFilter{MaxLen: 3}); err == nil {
+		t.Error("expected error when no joke fits max_len")
+	}
+	if _, err := registry.FetchFilteredJoke(ctx, JokeFilter{Sources: []string{"anekdot.ru"}}); err != errNoMatchingProviders {
+		t.Errorf("expected errNoMatchingProviders, got %v", err)
+	}
+}
+
+func TestGetRandomJokeHandler_Filters(t *testing.T) {
+	saved := providerRegistry
+	defer func() { providerRegistry = saved }()
+	providerRegistry = NewProviderRegistry(ProviderSpec{Provider: okProvider("jokeapi.dev"), Weight: 1})
+
+	cases := map[string]int{
+		"/random-joke?lang=en":                 http.StatusOK,
+		"/random-joke?source=jokeapi.dev":      http.StatusOK,
+		"/random-joke?source=unknown.example":  http.StatusBadRequest,
+		"/random-joke?lang=ru":                 http.StatusNotFound,
+		"/random-joke?source=rzhunemogu.ru":    http.StatusNotFound,
+		"/random-joke?lang=en&max_len=5000000": http.StatusOK,
+	}
+	for target, want := range cases {
+		w := httptest.NewRecorder()
+		getRandomJoke(w, httptest.NewRequest("GET", target, nil))
+		if w.Code != want {
+			t.Errorf("%s: expected %d, got %d", target, want, w.Code)
+		}
+		if want == http.StatusOK {
+			var joke Joke
+			if err := json.NewDecoder(w.Body).Decode(&joke); err != nil || joke.Source != "jokeapi.dev" {
+				t.Errorf("%s: unexpected body: %+v, %v", target, joke, err)
+			}
+		}
+	}
+}
+
+func TestArchiveProvider_Filter(t *testing.T) {
+	storage := openTestStorage(t)
+	ctx := context.Background()
+	storage.SaveJoke(ctx, Joke{Text: "Короткий", Source: "baneks.ru", IsRussian: true})
+	storage.SaveJoke(ctx, Joke{Text: "An English joke", Source: "jokeapi.dev"})
+	provider := NewArchiveProvider(storage)
+
+	joke, err := provider.FetchFilteredJoke(ctx, JokeFilter{Lang: "ru", MaxLen: 8})
+	if err != nil || joke.Source != "baneks.ru" {
+		t.Fatalf("expected russian joke from archive, got %+v, %v", joke, err)
+	}
+	if _, err := provider.FetchFilteredJoke(ctx, JokeFilter{Lang: "ru", MaxLen: 7}); err == nil {
+		t.Error("expected no joke shorter than 8 characters")
+	}
+}
@@ -7,12 +7,16 @@ function App() {
   const [translation, setTranslation] = useState('')
   const [showTranslation, setShowTranslation] = useState(false)
   const [isRussian, setIsRussian] = useState(true)
+  const [jokeId, setJokeId] = useState('')
+  const [voteStatus, setVoteStatus] = useState('')
 
   const loadJoke = async () => {
     setJoke('Загрузка...')
     setSource('')
     setShowTranslation(false)
     setTranslation('')
+    setJokeId('')
+    setVoteStatus('')
     
     try {
       const res = await fetch('/random-joke')
@@ -20,6 +24,7 @@ function App() {
       const data = await res.json()
       setJoke(data.joke || 'Нет анекдота')
       setSource(data.source ? `Источник: ${data.source}` : '')
+      setJokeId(data.id || '')
       
       // Простая проверка на русский текст
       setIsRussian(/[а-яА-ЯёЁ]/.test(data.joke))
@@ -48,6 +53,23 @@ function App() {
     }
   }
 
+  const voteJoke = async (vote) => {
+    if (!jokeId) return
+
+    try {
+      const res = await fetch(`/jokes/${jokeId}/vote`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ vote })
+      })
+      if (!res.ok) throw new Error('Ошибка голосования')
+      const data = await res.json()
+      setVoteStatus(`Спасибо за оценку! Рейтинг анекдота: ${data.score > 0 ? '+' : ''}${data.score}`)
+    } catch (e) {
+      setVoteStatus('Не удалось сохранить оценку')
+    }
+  }
+
   useEffect(() => {
     loadJoke()
   }, [])
@@ -62,7 +84,16 @@ function App() {
         {!isRussian && (
           <button className="btn" onClick={translateJoke}>Перевести</button>
         )}
+        {jokeId && (
+          <>
+            <button className="btn" onClick={() => voteJoke('up')} title="Нравится">👍</button>
+            <button className="btn" onClick={() => voteJoke('down')} title="Не нравится">👎</button>
+          </>
+        )}
       </div>
+      {voteStatus && (
+        <div className="source">{voteStatus}</div>
+      )}
       {showTranslation && (
         <div className="translation">{translation}</div>
       )}
@@ -6,7 +6,8 @@ export default defineConfig({
   server: {
     proxy: {
       '/random-joke': 'http://localhost:8888',
-      '/translate': 'http://localhost:8888'
+      '/translate': 'http://localhost:8888',
+      '/jokes': 'http://localhost:8888'
     }
   }
 })
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// botCommands — команды, на которые отвечает бот
+var botCommands = map[string]bool{
+	"start":         true,
+	"joke":          true,
+	"joke_ru":       true,
+	"top":           true,
+	"settings":      true,
+	"subscribe":     true,
+	"unsubscribe":   true,
+	"subscriptions": true,
+}
+
+// isGroupChat сообщает, что сообщение пришло из группы или супергруппы
+func isGroupChat(chat *tgbotapi.Chat) bool {
+	return chat != nil && (chat.IsGroup() || chat.IsSuperGroup())
+}
+
+// addressedToBot проверяет упоминание в команде: /joke@OtherBot адресована другому боту
+func (b *JokeBot) addressedToBot(message *tgbotapi.Message) bool {
+	_, mention, ok := strings.Cut(message.CommandWithAt(), "@")
+	return !ok || b.username == "" || strings.EqualFold(mention, b.username)
+}
+
+// replySender отправляет сообщения в чат ответом на исходную команду,
+// чтобы в группе было видно, кому адресован анекдот
+type replySender struct {
+	telegramSender
+	chatID    int64
+	messageID int
+}
+
+func (s replySender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	if msg, ok := c.(tgbotapi.MessageConfig); ok && msg.ChatID == s.chatID && msg.ReplyToMessageID == 0 {
+		msg.ReplyToMessageID = s.messageID
+		// Если команду успели удалить, ответ всё равно отправляется
+		msg.AllowSendingWithoutReply = true
+		c = msg
+	}
+	return s.telegramSender.Send(c)
+}
+
+// replyingTo возвращает копию бота, отвечающую на message
+func (b *JokeBot) replyingTo(message *tgbotapi.Message) *JokeBot {
+	reply := *b
+	reply.sender = replySender{telegramSender: b.sender, chatID: message.Chat.ID, messageID: message.MessageID}
+	return &reply
+}
+
+// allowCommand проверяет ограничение частоты команд; при первом отказе подряд
+// вежливо сообщает, когда можно повторить
+func (b *JokeBot) allowCommand(message *tgbotapi.Message) bool {
+	if b.limiter == nil {
+		return true
+	}
+	userID := message.Chat.ID
+	if message.From != nil {
+		userID = message.From.ID
+	}
+	ok, wait, notify := b.limiter.Allow(message.Chat.ID, userID)
+	if ok {
+		return true
+	}
+	logger.Debugf("Команда /%s в чате %d отклонена ограничением частоты", message.Command(), message.Chat.ID)
+	if notify {
+		seconds := int(math.Ceil(wait.Seconds()))
+		b.sender.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Не так быстро! Следующую команду можно отправить через %d с.", seconds)))
+	}
+	return false
+}
+
+// processMyChatMember приветствует чат, в который добавили бота, и отменяет
+// подписки чата, из которого бота удалили (или личного чата, где его заблокировали)
+func (b *JokeBot) processMyChatMember(update *tgbotapi.ChatMemberUpdated) {
+	chatID := update.Chat.ID
+	wasMember, isMember := isChatMember(update.OldChatMember), isChatMember(update.NewChatMember)
+	switch {
+	case !wasMember && isMember:
+		if update.Chat.IsPrivate() {
+			// Пользователь разблокировал бота — он получит приветствие по /start
+			return
+		}
+		logger.Infof("Бота добавили в чат %d (%s)", chatID, update.Chat.Title)
+		b.sender.Send(tgbotapi.NewMessage(chatID, b.groupGreeting()))
+	case wasMember && !isMember:
+		logger.Infof("Бот удалён из чата %d или заблокирован", chatID)
+		b.forgetChat(chatID)
+	}
+}
+
+// forgetChat отменяет подписки чата, в который бот больше не может писать
+func (b *JokeBot) forgetChat(chatID int64) {
+	if b.subscriptions == nil {
+		return
+	}
+	removed, err := b.subscriptions.Unsubscribe(context.Background(), chatID, "")
+	if err != nil {
+		logger.Errorf("Ошибка отмены подписок чата %d: %v", chatID, err)
+	} else if removed > 0 {
+		logger.Infof("Отменены подписки чата %d: %d", chatID, removed)
+	}
+}
+
+// isChatMember сообщает, что бот состоит в чате
+func isChatMember(member tgbotapi.ChatMember) bool {
+	return member.Status != "" && !member.HasLeft() && !member.WasKicked()
+}
+
+// groupGreeting возвращает приветствие для группы, в которую добавили бота
+func (b *JokeBot) groupGreeting() string {
+	text := "Всем привет! Я бот-анекдотчик 🤖\n\n/joke — случайный анекдот\n/joke_ru — русский анекдот\n/top — лучшие анекдоты\n/settings — настройки чата\n/subscribe 09:00 — анекдот каждое утро"
+	if b.username != "" {
+		text += "\n\nЕсли в группе несколько ботов, пишите команды с упоминанием: /joke@" + b.username
+	}
+	return text
+}
@@ -0,0 +1,103 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// groupCommandUpdate создаёт команду text от пользователя userID в группе chatID
+func groupCommandUpdate(chatID, userID int64, messageID int, text string) tgbotapi.Update {
+	command, _, _ := strings.Cut(text, " ")
+	return tgbotapi.Update{
+		Message: &tgbotapi.Message{
+			MessageID: messageID,
+			From:      &tgbotapi.User{ID: userID},
+			Chat:      &tgbotapi.Chat{ID: chatID, Type: "supergroup"},
+			Text:      text,
+			Entities:  []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}},
+		},
+	}
+}
+
+func TestProcessTelegramUpdate_GroupMentions(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	bot := newTestJokeBot(t, api)
+
+	bot.processTelegramUpdate(groupCommandUpdate(-300, 1, 7, "/joke@Joke_Bot"))
+	bot.processTelegramUpdate(groupCommandUpdate(-300, 1, 8, "/joke@other_bot"))
+	bot.processTelegramUpdate(groupCommandUpdate(-300, 1, 9, "/weather"))
+
+	calls := api.callsTo("sendMessage")
+	if len(calls) != 1 || calls[0].Params["text"] != "Test joke" {
+		t.Fatalf("expected a single joke, got %v", calls)
+	}
+	if calls[0].Params["reply_to_message_id"] != "7" || calls[0].Params["allow_sending_without_reply"] != "true" {
+		t.Errorf("joke is not a reply to the command: %v", calls[0].Params)
+	}
+}
+
+func TestProcessTelegramUpdate_PrivateUnknownCommand(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	newTestJokeBot(t, api).processTelegramUpdate(commandUpdate(301, "weather"))
+	calls := api.callsTo("sendMessage")
+	if len(calls) != 1 || calls[0].Params["reply_to_message_id"] != "" {
+		t.Errorf("expected a plain hint in a private chat, got %v", calls)
+	}
+}
+
+func TestProcessTelegramUpdate_GroupRateLimit(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	bot := newTestJokeBot(t, api)
+	bot.limiter = NewCommandLimiter(BotRateLimitConfig{User: TokenBucketConfig{Burst: 2}})
+
+	for i := 0; i < 4; i++ {
+		bot.processTelegramUpdate(groupCommandUpdate(-310, 1, 10+i, "/top"))
+	}
+	// Другой участник группы ограничен отдельно
+	bot.processTelegramUpdate(groupCommandUpdate(-310, 2, 20, "/top"))
+
+	texts := sentTexts(api)
+	if len(texts) != 4 || !strings.HasPrefix(texts[2], "Не так быстро!") || strings.HasPrefix(texts[3], "Не так быстро!") {
+		t.Errorf("unexpected replies: %q", texts)
+	}
+}
+
+func TestProcessTelegramUpdate_MyChatMember(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	bot := newTestJokeBot(t, api)
+	scheduler, err := NewSubscriptionScheduler(SubscriptionsConfig{}, nil, func(Subscription) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot.subscriptions = scheduler
+	chat := tgbotapi.Chat{ID: -320, Type: "group", Title: "Друзья"}
+	memberUpdate := func(oldStatus, newStatus string) tgbotapi.Update {
+		return tgbotapi.Update{MyChatMember: &tgbotapi.ChatMemberUpdated{
+			Chat:          chat,
+			OldChatMember: tgbotapi.ChatMember{Status: oldStatus},
+			NewChatMember: tgbotapi.ChatMember{Status: newStatus},
+		}}
+	}
+
+	bot.processTelegramUpdate(memberUpdate("left", "member"))
+	if texts := sentTexts(api); len(texts) != 1 || !strings.Contains(texts[0], "/joke@joke_bot") {
+		t.Fatalf("expected greeting, got %q", texts)
+	}
+
+	if err := scheduler.Subscribe(context.Background(), Subscription{ChatID: -320, At: "09:00", Timezone: "UTC"}); err != nil {
+		t.Fatal(err)
+	}
+	bot.processTelegramUpdate(memberUpdate("member", "kicked"))
+	if subs := scheduler.List(-320); len(subs) != 0 {
+		t.Errorf("subscriptions left after removal: %v", subs)
+	}
+	if texts := sentTexts(api); len(texts) != 1 {
+		t.Errorf("unexpected messages after removal: %q", texts)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Параметры проверки готовности по умолчанию
+const (
+	defaultHealthMaxSuccessAge = 10 * time.Minute
+	defaultHealthProbeInterval = time.Minute
+	healthProbeTimeout         = 10 * time.Second
+)
+
+// HealthConfig описывает проверку готовности сервиса (/readyz)
+type HealthConfig struct {
+	// MaxSuccessAge — насколько давно хотя бы один провайдер должен был успешно отдать анекдот
+	MaxSuccessAge time.Duration `yaml:"max_success_age"`
+	// ProbeInterval — как часто опрашивать провайдеров, к которым давно не было успешных запросов
+	ProbeInterval time.Duration `yaml:"probe_interval"`
+}
+
+func (c HealthConfig) validate() error {
+	if c.MaxSuccessAge < 0 {
+		return fmt.Errorf("health.max_success_age не может быть отрицательным")
+	}
+	if c.ProbeInterval < 0 {
+		return fmt.Errorf("health.probe_interval не может быть отрицательным")
+	}
+	return nil
+}
+
+// withDefaults возвращает настройки с подставленными значениями по умолчанию
+func (c HealthConfig) withDefaults() HealthConfig {
+	if c.MaxSuccessAge <= 0 {
+		c.MaxSuccessAge = defaultHealthMaxSuccessAge
+	}
+	if c.ProbeInterval <= 0 {
+		c.ProbeInterval = defaultHealthProbeInterval
+	}
+	return c
+}
+
+// Probe запрашивает анекдот у каждого доступного провайдера, не отдававшего
+// анекдотов дольше staleAfter. Результаты учитываются в статистике и выключателях.
+// Буфер предзагрузки не расходуется: проверяется сам провайдер.
+func (r *ProviderRegistry) Probe(ctx context.Context, staleAfter time.Duration) {
+	now := r.now()
+	var wg sync.WaitGroup
+	for _, e := range r.entries {
+		e.mu.Lock()
+		fresh := now.Sub(e.lastSuccess) < staleAfter
+		e.mu.Unlock()
+		if e.weight <= 0 || fresh || !r.available(e, now) || !e.acquire() {
+			continue
+		}
+		wg.Add(1)
+		go func(e *registeredProvider) {
+			defer wg.Done()
+			start := r.now()
+			_, err := e.fetchUpstream(ctx)
+			latency := r.now().Sub(start)
+			switch {
+			case err == nil:
+				r.recordFetch(e, latency, nil)
+			case ctx.Err() != nil:
+				e.release()
+			default:
+				logger.Warnf("Проверка провайдера %s не удалась: %v", e.provider.Name(), err)
+				r.recordFetch(e, latency, err)
+			}
+		}(e)
+	}
+	wg.Wait()
+}
+
+// HealthProber в фоне опрашивает провайдеров, чтобы /readyz отражал их состояние
+// и при отсутствии пользовательских запросов
+type HealthProber struct {
+	registry *ProviderRegistry
+	cfg      HealthConfig
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewHealthProber создаёт опрос провайдеров; он начинается после Start
+func NewHealthProber(registry *ProviderRegistry, cfg HealthConfig) *HealthProber {
+	return &HealthProber{registry: registry, cfg: cfg.withDefaults()}
+}
+
+// Start сразу опрашивает провайдеров, а затем повторяет опрос каждые ProbeInterval
+func (p *HealthProber) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(p.cfg.ProbeInterval)
+		defer ticker.Stop()
+		for {
+			probeCtx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+			p.registry.Probe(probeCtx, p.cfg.ProbeInterval)
+			cancel()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop останавливает опрос и ждёт его завершения
+func (p *HealthProber) Stop() {
+	p.mu.Lock()
+	cancel := p.cancel
+	p.cancel = nil
+	p.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		p.wg.Wait()
+	}
+}
+
+// Состояния проверок готовности
+const (
+	checkOK       = "ok"
+	checkDisabled = "disabled"
+	checkFailed   = "failed"
+)
+
+// ProviderHealth — состояние провайдера в ответе /readyz
+type ProviderHealth struct {
+	Name        string     `json:"name"`
+	State       string     `json:"state"`
+	LastSuccess *time.Time `json:"last_success"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// Readiness — ответ /readyz
+type Readiness struct {
+	Ready     bool              `json:"ready"`
+	Checks    map[string]string `json:"checks"`
+	Providers []ProviderHealth  `json:"providers"`
+}
+
+// checkReadiness проверяет конфигурацию, Telegram-бота и провайдеров на момент now
+func checkReadiness(now time.Time) Readiness {
+	readiness := Readiness{Ready: true, Checks: make(map[string]string)}
+	check := func(name string, ok bool) {
+		if ok {
+			readiness.Checks[name] = checkOK
+		} else {
+			readiness.Checks[name] = checkFailed
+			readiness.Ready = false
+		}
+	}
+
+	check("config", appConfig != nil)
+	if appConfig != nil && appConfig.TelegramBotToken == "" {
+		readiness.Checks["telegram"] = checkDisabled
+	} else {
+		check("telegram", jokeBot.Load() != nil)
+	}
+
+	var cfg HealthConfig
+	if appConfig != nil {
+		cfg = appConfig.Health
+	}
+	maxAge := cfg.withDefaults().MaxSuccessAge
+	recent := false
+	for _, stats := range providerRegistry.Stats() {
+		health := ProviderHealth{Name: stats.Name, State: stats.State, LastError: stats.LastError}
+		if !stats.LastSuccess.IsZero() {
+			lastSuccess := stats.LastSuccess
+			health.LastSuccess = &lastSuccess
+			recent = recent || now.Sub(lastSuccess) <= maxAge
+		}
+		readiness.Providers = append(readiness.Providers, health)
+	}
+	check("providers", recent)
+	return readiness
+}
+
+// healthzHandler обрабатывает GET /healthz: процесс жив и отвечает на запросы
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": checkOK})
+}
+
+// readyzHandler обрабатывает GET /readyz: 200, если сервис готов обслуживать запросы, иначе 503
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	readiness := checkReadiness(time.Now())
+	w.Header().Set("Content-Type", "application/json")
+	if !readiness.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(readiness)
+}
@@ -0,0 +1,102 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProviderRegistry_Probe(t *testing.T) {
+	good, bad := okProvider("good"), failingProvider("bad")
+	registry := NewProviderRegistry(ProviderSpec{Provider: good, Weight: 1}, ProviderSpec{Provider: bad, Weight: 1})
+	now := time.Unix(1000, 0)
+	registry.now = func() time.Time { return now }
+
+	registry.Probe(context.Background(), time.Minute)
+	stats := registry.Stats()
+	if !stats[0].LastSuccess.Equal(now) || stats[1].Failures != 1 || stats[1].LastError == "" {
+		t.Fatalf("unexpected stats after probe: %+v", stats)
+	}
+
+	// Провайдер с недавним успехом повторно не опрашивается
+	now = now.Add(30 * time.Second)
+	registry.Probe(context.Background(), time.Minute)
+	if good.calls != 1 || bad.calls != 2 {
+		t.Errorf("calls: good=%d bad=%d, want 1 and 2", good.calls, bad.calls)
+	}
+}
+
+func TestProviderRegistry_ProbeBypassesPrefetchBuffer(t *testing.T) {
+	inner := &countingProvider{}
+	registry := NewProviderRegistry(ProviderSpec{Provider: inner, Weight: 1})
+	var prefetcher *PrefetchProvider
+	registry.Wrap(func(p JokeProvider) JokeProvider {
+		prefetcher = NewPrefetchProvider(p, PrefetchConfig{BufferSize: 2})
+		return prefetcher
+	})
+	prefetcher.buffer <- Joke{Text: "buffered", Source: "counting"}
+
+	registry.Probe(context.Background(), time.Minute)
+	if prefetcher.Buffered() != 1 {
+		t.Errorf("probe took a joke from the prefetch buffer, %d left", prefetcher.Buffered())
+	}
+	if stats := registry.Stats()[0]; inner.calls.Load() != 1 || stats.Successes != 1 {
+		t.Errorf("expected one upstream probe recorded, got calls=%d stats=%+v", inner.calls.Load(), stats)
+	}
+}
+
+func TestReadyzHandler(t *testing.T) {
+	savedConfig, savedRegistry := appConfig, providerRegistry
+	defer func() { appConfig, providerRegistry = savedConfig, savedRegistry }()
+	appConfig = &Config{Health: HealthConfig{MaxSuccessAge: time.Minute}}
+	providerRegistry = NewProviderRegistry(
+		ProviderSpec{Provider: okProvider("good"), Weight: 1},
+		ProviderSpec{Provider: failingProvider("bad"), Weight: 0},
+	)
+
+	readyz := func() (int, Readiness) {
+		w := httptest.NewRecorder()
+		readyzHandler(w, httptest.NewRequest("GET", "/readyz", nil))
+		var readiness Readiness
+		if err := json.NewDecoder(w.Body).Decode(&readiness); err != nil {
+			t.Fatalf("invalid /readyz body: %v", err)
+		}
+		return w.Code, readiness
+	}
+
+	code, readiness := readyz()
+	if code != http.StatusServiceUnavailable || readiness.Checks["providers"] != checkFailed || readiness.Checks["telegram"] != checkDisabled {
+		t.Fatalf("before any fetch: %d %+v", code, readiness)
+	}
+
+	if _, err := providerRegistry.FetchJoke(context.Background()); err != nil {
+		t.Fatalf("FetchJoke error: %v", err)
+	}
+	code, readiness = readyz()
+	if code != http.StatusOK || !readiness.Ready || len(readiness.Providers) != 2 {
+		t.Fatalf("after fetch: %d %+v", code, readiness)
+	}
+	if readiness.Providers[0].LastSuccess == nil || readiness.Providers[1].LastSuccess != nil {
+		t.Errorf("unexpected providers: %+v", readiness.Providers)
+	}
+
+	// Telegram-бот настроен, но ещё не запущен
+	appConfig.TelegramBotToken = "token"
+	if code, readiness = readyz(); code != http.StatusServiceUnavailable || readiness.Checks["telegram"] != checkFailed {
+		t.Errorf("telegram not initialized: %d %+v", code, readiness)
+	}
+}
+
+func TestHealthzHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	healthzHandler(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("/healthz = %d", w.Code)
+	}
+}
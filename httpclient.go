@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Параметры исходящего HTTP-клиента по умолчанию
+const (
+	defaultHTTPTimeout      = 10 * time.Second
+	defaultHTTPMaxBodySize  = 2 << 20
+	defaultHTTPMaxRetries   = 2
+	defaultHTTPRetryBackoff = 200 * time.Millisecond
+	defaultHTTPMaxRetryWait = 5 * time.Second
+)
+
+// defaultHTTPUserAgent подставляется в запросы, для которых провайдер не задал свой User-Agent
+var defaultHTTPUserAgent = "joke-service/" + Version
+
+// errResponseTooLarge возвращается при чтении ответа больше http_client.max_body_size
+var errResponseTooLarge = errors.New("ответ превышает допустимый размер")
+
+// HTTPClientConfig описывает общий HTTP-клиент для запросов к провайдерам и переводчикам
+type HTTPClientConfig struct {
+	// Timeout — общее время запроса вместе с повторами и чтением ответа
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxBodySize — максимальный размер ответа в байтах
+	MaxBodySize int64 `yaml:"max_body_size"`
+	// Proxy — адрес прокси; если не задан, используются HTTP_PROXY/HTTPS_PROXY
+	Proxy string `yaml:"proxy"`
+	// UserAgent — User-Agent для провайдеров без своего user_agent
+	UserAgent string `yaml:"user_agent"`
+	// MaxRetries — сколько раз повторять идемпотентный запрос после 5xx, 429 или сетевой ошибки (0 — не повторять)
+	MaxRetries *int `yaml:"max_retries"`
+	// RetryBackoff — пауза перед первым повтором, дальше она удваивается
+	RetryBackoff time.Duration `yaml:"retry_backoff"`
+	// MaxRetryWait — если Retry-After просит ждать дольше, запрос не повторяется
+	MaxRetryWait time.Duration `yaml:"max_retry_wait"`
+}
+
+func (c HTTPClientConfig) validate() error {
+	if c.Timeout < 0 {
+		return fmt.Errorf("http_client.timeout не может быть отрицательным")
+	}
+	if c.MaxBodySize < 0 {
+		return fmt.Errorf("http_client.max_body_size не может быть отрицательным")
+	}
+	if c.MaxRetries != nil && *c.MaxRetries < 0 {
+		return fmt.Errorf("http_client.max_retries не может быть отрицательным")
+	}
+	if c.RetryBackoff < 0 {
+		return fmt.Errorf("http_client.retry_backoff не может быть отрицательным")
+	}
+	if c.MaxRetryWait < 0 {
+		return fmt.Errorf("http_client.max_retry_wait не может быть отрицательным")
+	}
+	if c.Proxy != "" {
+		if u, err := url.Parse(c.Proxy); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("некорректный http_client.proxy %q", c.Proxy)
+		}
+	}
+	return nil
+}
+
+// NewHTTPClient создаёт исходящий HTTP-клиент: с таймаутом, ограничением размера
+// ответа, повторами после 5xx и 429 (с учётом Retry-After) и трассировкой каждой попытки
+func NewHTTPClient(cfg HTTPClientConfig) (*http.Client, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.MaxIdleConnsPerHost = 10
+	if cfg.Proxy != "" {
+		proxy, _ := url.Parse(cfg.Proxy)
+		base.Proxy = http.ProxyURL(proxy)
+	}
+	transport := &outboundTransport{
+		base:         tracingTransport{base: base},
+		userAgent:    orDefault(cfg.UserAgent, defaultHTTPUserAgent),
+		maxBodySize:  cfg.MaxBodySize,
+		maxRetries:   defaultHTTPMaxRetries,
+		retryBackoff: cfg.RetryBackoff,
+		maxRetryWait: cfg.MaxRetryWait,
+		wait:         sleepContext,
+	}
+	if transport.maxBodySize <= 0 {
+		transport.maxBodySize = defaultHTTPMaxBodySize
+	}
+	if cfg.MaxRetries != nil {
+		transport.maxRetries = *cfg.MaxRetries
+	}
+	if transport.retryBackoff <= 0 {
+		transport.retryBackoff = defaultHTTPRetryBackoff
+	}
+	if transport.maxRetryWait <= 0 {
+		transport.maxRetryWait = defaultHTTPMaxRetryWait
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+// mustNewHTTPClient — вариант NewHTTPClient для встроенных настроек
+func mustNewHTTPClient(cfg HTTPClientConfig) *http.Client {
+	client, err := NewHTTPClient(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
+
+// httpClientOr возвращает client или общий клиент, если client не задан
+func httpClientOr(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+	return upstreamClient
+}
+
+// outboundTransport подставляет User-Agent, повторяет неудачные запросы и
+// ограничивает размер ответа. Повторяются только идемпотентные запросы (GET, HEAD,
+// OPTIONS, PUT, DELETE); остальные — лишь если вызывающий разрешил это через
+// withRetryableRequest и тело запроса можно отправить заново.
+type outboundTransport struct {
+	base         http.RoundTripper
+	userAgent    string
+	maxBodySize  int64
+	maxRetries   int
+	retryBackoff time.Duration
+	maxRetryWait time.Duration
+	wait         func(ctx context.Context, d time.Duration) bool
+}
+
+func (t *outboundTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	host := req.URL.Host
+	for attempt := 0; ; attempt++ {
+		attemptReq, err := t.prepare(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := t.base.RoundTrip(attemptReq)
+		observeOutboundRequest(host, resp, err)
+
+		delay, retry := t.retryDelay(req, resp, err, attempt)
+		if !retry || ctx.Err() != nil {
+			if err != nil {
+				return nil, err
+			}
+			return t.limit(resp)
+		}
+		if resp != nil {
+			// Тело читается, чтобы соединение можно было переиспользовать
+			io.Copy(io.Discard, io.LimitReader(resp.Body, t.maxBodySize))
+			resp.Body.Close()
+		}
+		observeOutboundRetry(host)
+		logFor(ctx).Debugf("Повтор запроса к %s через %s (попытка %d)", host, delay, attempt+2)
+		if !t.wait(ctx, delay) {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// prepare копирует запрос для очередной попытки: с новым телом, User-Agent и номером повтора
+func (t *outboundTransport) prepare(req *http.Request, attempt int) (*http.Request, error) {
+	clone := req.Clone(withResendCount(req.Context(), attempt))
+	if attempt > 0 && req.Body != nil && req.Body != http.NoBody {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+	if clone.Header.Get("User-Agent") == "" && t.userAgent != "" {
+		clone.Header.Set("User-Agent", t.userAgent)
+	}
+	return clone, nil
+}
+
+// retryDelay решает, повторять ли запрос, и возвращает паузу перед повтором
+func (t *outboundTransport) retryDelay(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= t.maxRetries || !retryable(req) || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return 0, false
+	}
+	backoff := t.retryBackoff << attempt
+	// Случайный разброс, чтобы повторы разных запросов не совпадали
+	backoff = backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+	if err != nil {
+		// Несуществующий хост не появится за время повторов
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return 0, false
+		}
+		var netErr net.Error
+		return backoff, errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+	}
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < http.StatusInternalServerError {
+		return 0, false
+	}
+	if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+		return wait, wait <= t.maxRetryWait
+	}
+	return backoff, true
+}
+
+// retryable сообщает, можно ли повторить запрос: идемпотентный метод или явное разрешение
+func retryable(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	allowed, _ := req.Context().Value(retryableRequestKey{}).(bool)
+	return allowed
+}
+
+// limit ограничивает размер тела ответа
+func (t *outboundTransport) limit(resp *http.Response) (*http.Response, error) {
+	if resp.ContentLength > t.maxBodySize {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %d байт", errResponseTooLarge, resp.ContentLength)
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: t.maxBodySize}
+	return resp, nil
+}
+
+// limitedBody возвращает errResponseTooLarge, если в теле больше remaining байт
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, errResponseTooLarge
+	}
+	// Читаем на байт больше лимита, чтобы отличить ответ ровно в лимит от превышения
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), errResponseTooLarge
+	}
+	return n, err
+}
+
+// parseRetryAfter разбирает заголовок Retry-After: число секунд или дату
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := at.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+type retryableRequestKey struct{}
+
+// withRetryableRequest разрешает повторять неидемпотентный запрос с контекстом ctx,
+// например POST, который ничего не меняет на сервере
+func withRetryableRequest(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryableRequestKey{}, true)
+}
+
+type resendCountKey struct{}
+
+// withResendCount сохраняет в контексте номер повтора запроса (0 — первая попытка)
+func withResendCount(ctx context.Context, count int) context.Context {
+	return context.WithValue(ctx, resendCountKey{}, count)
+}
+
+// resendCountFromContext возвращает номер повтора запроса
+func resendCountFromContext(ctx context.Context) int {
+	count, _ := ctx.Value(resendCountKey{}).(int)
+	return count
+}
@@ -0,0 +1,252 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestHTTPClient создаёт клиент, который не ждёт между повторами, а записывает паузы в waits
+func newTestHTTPClient(t *testing.T, cfg HTTPClientConfig) (*http.Client, *[]time.Duration) {
+	t.Helper()
+	client, err := NewHTTPClient(cfg)
+	if err != nil {
+		t.Fatalf("NewHTTPClient error: %v", err)
+	}
+	var mu sync.Mutex
+	var waits []time.Duration
+	client.Transport.(*outboundTransport).wait = func(ctx context.Context, d time.Duration) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		waits = append(waits, d)
+		return ctx.Err() == nil
+	}
+	return client, &waits
+}
+
+// sequenceServer отвечает статусами из statuses по очереди, затем 200 с телом body
+func sequenceServer(t *testing.T, body string, statuses ...int) (*httptest.Server, *[]*http.Request) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		payload, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(strings.NewReader(string(payload)))
+		requests = append(requests, r)
+		if len(statuses) > 0 {
+			status := statuses[0]
+			statuses = statuses[1:]
+			if status == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "3")
+			}
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestHTTPClient_RetriesServerErrors(t *testing.T) {
+	server, requests := sequenceServer(t, "ok", http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	client, waits := newTestHTTPClient(t, HTTPClientConfig{RetryBackoff: 100 * time.Millisecond})
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(*requests) != 3 {
+		t.Fatalf("status %d after %d requests, want 200 after 3", resp.StatusCode, len(*requests))
+	}
+	if len(*waits) != 2 || (*waits)[0] > 100*time.Millisecond || (*waits)[1] != 3*time.Second {
+		t.Errorf("waits = %v, want backoff then Retry-After 3s", *waits)
+	}
+	if ua := (*requests)[0].Header.Get("User-Agent"); ua != defaultHTTPUserAgent {
+		t.Errorf("User-Agent = %q, want %q", ua, defaultHTTPUserAgent)
+	}
+}
+
+func TestHTTPClient_NoRetry(t *testing.T) {
+	zero := 0
+	cases := []struct {
+		name     string
+		cfg      HTTPClientConfig
+		statuses []int
+		want     int
+	}{
+		{"client error", HTTPClientConfig{}, []int{http.StatusNotFound}, http.StatusNotFound},
+		{"retries disabled", HTTPClientConfig{MaxRetries: &zero}, []int{http.StatusBadGateway}, http.StatusBadGateway},
+		{"retry after too long", HTTPClientConfig{MaxRetryWait: time.Second}, []int{http.StatusTooManyRequests}, http.StatusTooManyRequests},
+		{"retries exhausted", HTTPClientConfig{}, []int{500, 500, 500}, http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server, requests := sequenceServer(t, "ok", tc.statuses...)
+			client, _ := newTestHTTPClient(t, tc.cfg)
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatalf("Get error: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.want || len(*requests) != len(tc.statuses) {
+				t.Errorf("status %d after %d requests, want %d after %d", resp.StatusCode, len(*requests), tc.want, len(tc.statuses))
+			}
+		})
+	}
+}
+
+func TestHTTPClient_PostNotRetriedByDefault(t *testing.T) {
+	server, requests := sequenceServer(t, "ok", http.StatusBadGateway)
+	client, _ := newTestHTTPClient(t, HTTPClientConfig{})
+
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"q":"hello"}`))
+	if err != nil {
+		t.Fatalf("Post error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway || len(*requests) != 1 {
+		t.Errorf("status %d after %d requests, want 502 after 1", resp.StatusCode, len(*requests))
+	}
+}
+
+func TestHTTPClient_RetryReplaysBody(t *testing.T) {
+	server, requests := sequenceServer(t, "ok", http.StatusBadGateway)
+	client, _ := newTestHTTPClient(t, HTTPClientConfig{})
+
+	req, _ := http.NewRequestWithContext(withRetryableRequest(context.Background()), "POST", server.URL, strings.NewReader(`{"q":"hello"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do error: %v", err)
+	}
+	resp.Body.Close()
+	if len(*requests) != 2 {
+		t.Fatalf("requests = %d, want 2", len(*requests))
+	}
+	for i, r := range *requests {
+		if body, _ := io.ReadAll(r.Body); string(body) != `{"q":"hello"}` {
+			t.Errorf("request %d body = %q", i, body)
+		}
+	}
+}
+
+func TestHTTPClient_MaxBodySize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunked" {
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(strings.Repeat("a", 20)))
+	}))
+	defer server.Close()
+	client, _ := newTestHTTPClient(t, HTTPClientConfig{MaxBodySize: 10})
+
+	if _, err := client.Get(server.URL + "/sized"); !errors.Is(err, errResponseTooLarge) {
+		t.Errorf("Get with Content-Length error = %v, want errResponseTooLarge", err)
+	}
+	resp, err := client.Get(server.URL + "/chunked")
+	if err != nil {
+		t.Fatalf("Get error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if !errors.Is(err, errResponseTooLarge) || len(body) != 10 {
+		t.Errorf("ReadAll = %d bytes, %v; want 10 bytes and errResponseTooLarge", len(body), err)
+	}
+
+	exact, _ := newTestHTTPClient(t, HTTPClientConfig{MaxBodySize: 20})
+	resp, err = exact.Get(server.URL + "/chunked")
+	if err != nil {
+		t.Fatalf("Get error: %v", err)
+	}
+	defer resp.Body.Close()
+	if body, err := io.ReadAll(resp.Body); err != nil || len(body) != 20 {
+		t.Errorf("ReadAll = %d bytes, %v; want the whole 20-byte body", len(body), err)
+	}
+}
+
+func TestHTTPClient_Proxy(t *testing.T) {
+	var target string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target = r.URL.String()
+		w.Write([]byte("from proxy"))
+	}))
+	defer proxy.Close()
+	client, _ := newTestHTTPClient(t, HTTPClientConfig{Proxy: proxy.URL})
+
+	resp, err := client.Get("http://jokes.example/random")
+	if err != nil {
+		t.Fatalf("Get error: %v", err)
+	}
+	resp.Body.Close()
+	if target != "http://jokes.example/random" {
+		t.Errorf("proxy got %q, want the absolute target URL", target)
+	}
+}
+
+func TestHTTPClientConfig_Validate(t *testing.T) {
+	negative := -1
+	invalid := []HTTPClientConfig{
+		{Timeout: -time.Second},
+		{MaxBodySize: -1},
+		{MaxRetries: &negative},
+		{Proxy: "localhost:3128"},
+	}
+	for _, cfg := range invalid {
+		if err := cfg.validate(); err == nil {
+			t.Errorf("validate(%+v) = nil, want error", cfg)
+		}
+	}
+	if err := (HTTPClientConfig{Proxy: "http://proxy:3128", Timeout: time.Second}).validate(); err != nil {
+		t.Errorf("validate() = %v, want nil", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"5", 5 * time.Second, true},
+		{now.Add(time.Minute).Format(http.TimeFormat), time.Minute, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"", 0, false},
+		{"soon", 0, false},
+	}
+	for _, tc := range cases {
+		if got, ok := parseRetryAfter(tc.value, now); got != tc.want || ok != tc.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tc.value, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestProviders_UseInjectedClient(t *testing.T) {
+	server, requests := sequenceServer(t, `<meta name="description" content="Анекдот">`, http.StatusServiceUnavailable)
+	client, _ := newTestHTTPClient(t, HTTPClientConfig{UserAgent: "test-agent"})
+
+	registry, err := newProviderRegistryFromConfig([]ProviderConfig{{Name: "baneks.ru", Weight: 1, BaseURL: server.URL}}, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	joke, err := registry.FetchJoke(context.Background())
+	if err != nil || joke.Text != "Анекдот" {
+		t.Fatalf("FetchJoke() = %+v, %v", joke, err)
+	}
+	if len(*requests) != 2 || (*requests)[1].Header.Get("User-Agent") != "test-agent" {
+		t.Errorf("requests = %d, want a retry with the client's User-Agent", len(*requests))
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Параметры inline-режима
+const (
+	inlineResultCount    = 5
+	inlineTimeout        = 4 * time.Second
+	inlineCacheTime      = 5 // секунд: повторный запрос должен давать новые анекдоты
+	inlineTitleMaxLength = 60
+)
+
+// parseInlineQuery превращает текст inline-запроса в фильтр: ru/en — язык,
+// имя источника (anekdot.ru или просто anekdot) — источник. Остальные слова игнорируются.
+func parseInlineQuery(query string) JokeFilter {
+	var f JokeFilter
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		switch word {
+		case langRussian, langEnglish:
+			f.Lang = word
+			continue
+		}
+		for name := range providerCatalog {
+			if word == name || word == strings.SplitN(name, ".", 2)[0] {
+				f.Sources = append(f.Sources, name)
+				break
+			}
+		}
+	}
+	return f
+}
+
+// inlineTitle возвращает начало анекдота для заголовка результата
+func inlineTitle(text string) string {
+	return truncateText(strings.Join(strings.Fields(text), " "), inlineTitleMaxLength)
+}
+
+// truncateText обрезает текст до n символов, заканчивая многоточием
+func truncateText(text string, n int) string {
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:n-1]) + "…"
+}
+
+// processInlineQuery отвечает на inline-запрос несколькими анекдотами от разных провайдеров
+func (b *JokeBot) processInlineQuery(query *tgbotapi.InlineQuery) {
+	filter := parseInlineQuery(query.Query)
+	ctx, cancel := context.WithTimeout(context.Background(), inlineTimeout)
+	defer cancel()
+	batch := b.fetchBatch(ctx, inlineResultCount, filter)
+	if len(batch.Jokes) == 0 {
+		logger.Warnf("Inline-запрос %q: анекдоты не получены", query.Query)
+	}
+
+	results := make([]interface{}, 0, len(batch.Jokes))
+	for _, joke := range batch.Jokes {
+		id := b.memory.Remember(ctx, joke)
+		article := tgbotapi.NewInlineQueryResultArticle(id, inlineTitle(joke.Text), joke.Text)
+		article.Description = joke.Source
+		// Перевод требует сообщения в чате бота, поэтому под inline-анекдотом только оценка
+		keyboard := jokeKeyboard(id, false)
+		article.ReplyMarkup = &keyboard
+		results = append(results, article)
+	}
+	answer := tgbotapi.InlineConfig{
+		InlineQueryID: query.ID,
+		Results:       results,
+		CacheTime:     inlineCacheTime,
+		IsPersonal:    true,
+	}
+	if _, err := b.sender.Request(answer); err != nil {
+		logger.Errorf("Ошибка ответа на inline-запрос: %v", err)
+	}
+}
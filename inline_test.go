@@ -0,0 +1,71 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestParseInlineQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  JokeFilter
+	}{
+		{"", JokeFilter{}},
+		{"RU", JokeFilter{Lang: langRussian}},
+		{"en jokeapi.dev", JokeFilter{Lang: langEnglish, Sources: []string{"jokeapi.dev"}}},
+		{"anekdot про Штирлица", JokeFilter{Sources: []string{"anekdot.ru"}}},
+	}
+	for _, tt := range tests {
+		if got := parseInlineQuery(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseInlineQuery(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestInlineTitle(t *testing.T) {
+	long := "Штирлиц долго смотрел в одну точку. Потом в другую. «Двоеточие!» — наконец-то догадался он."
+	title := inlineTitle(long)
+	if utf8.RuneCountInString(title) != inlineTitleMaxLength {
+		t.Errorf("expected title of %d runes, got %q", inlineTitleMaxLength, title)
+	}
+	if got := inlineTitle("Short\njoke"); got != "Short joke" {
+		t.Errorf("unexpected title %q", got)
+	}
+}
+
+func TestProcessTelegramUpdate_InlineQuery(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	bot := newTestJokeBot(t, api)
+	var gotFilter JokeFilter
+	bot.fetchBatch = func(ctx context.Context, count int, filter JokeFilter) JokeBatch {
+		gotFilter = filter
+		return JokeBatch{Jokes: []Joke{
+			{Text: "Первый анекдот", Source: "anekdot.ru", IsRussian: true},
+			{Text: "Второй анекдот", Source: "anekdot.ru", IsRussian: true},
+		}}
+	}
+	bot.processTelegramUpdate(tgbotapi.Update{InlineQuery: &tgbotapi.InlineQuery{ID: "q1", Query: "ru anekdot"}})
+
+	if gotFilter.Lang != langRussian || len(gotFilter.Sources) != 1 {
+		t.Errorf("unexpected filter %+v", gotFilter)
+	}
+	calls := api.callsTo("answerInlineQuery")
+	if len(calls) != 1 || calls[0].Params["inline_query_id"] != "q1" {
+		t.Fatalf("unexpected answerInlineQuery calls: %v", calls)
+	}
+	var results []tgbotapi.InlineQueryResultArticle
+	if err := json.Unmarshal([]byte(calls[0].Params["results"]), &results); err != nil {
+		t.Fatalf("invalid results %q: %v", calls[0].Params["results"], err)
+	}
+	if len(results) != 2 || results[0].ID == results[1].ID || results[0].Description != "anekdot.ru" {
+		t.Errorf("unexpected results: %+v", results)
+	}
+}
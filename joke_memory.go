@@ -0,0 +1,232 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Параметры памяти анекдотов бота по умолчанию
+const (
+	defaultJokeMemorySize = 10000
+	defaultJokeMemoryTTL  = 48 * time.Hour
+
+	// jokeIDLength — длина идентификатора анекдота в callback_data (лимит Telegram — 64 байта)
+	jokeIDLength = 16
+
+	// jokeMemoryPruneEvery — через сколько сохранений из хранилища удаляются устаревшие анекдоты
+	jokeMemoryPruneEvery = 500
+)
+
+// translateCallbackPrefix — префикс callback_data кнопки перевода, за ним следует ID анекдота
+const translateCallbackPrefix = "translate_joke:"
+
+// JokeMemoryConfig описывает память анекдотов, отправленных ботом с кнопкой перевода
+type JokeMemoryConfig struct {
+	// Size — максимальное число анекдотов в памяти
+	Size int           `yaml:"size"`
+	TTL  time.Duration `yaml:"ttl"`
+	// Persist сохраняет анекдоты в хранилище SQLite, чтобы кнопки работали после перезапуска
+	Persist bool `yaml:"persist"`
+}
+
+func (c JokeMemoryConfig) validate() error {
+	if c.Size < 0 {
+		return fmt.Errorf("telegram.joke_memory.size не может быть отрицательным")
+	}
+	if c.TTL < 0 {
+		return fmt.Errorf("telegram.joke_memory.ttl не может быть отрицательным")
+	}
+	return nil
+}
+
+// jokeMemoryStore — постоянное хранилище анекдотов бота
+type jokeMemoryStore interface {
+	LoadBotJoke(ctx context.Context, id string, notBefore time.Time) (Joke, bool, error)
+	SaveBotJoke(ctx context.Context, id string, joke Joke) error
+	PruneBotJokes(ctx context.Context, notBefore time.Time) error
+}
+
+// jokeID возвращает короткий идентификатор анекдота для callback_data
+func jokeID(text string) string {
+	return jokeHash(text)[:jokeIDLength]
+}
+
+// translateCallbackData возвращает callback_data кнопки перевода анекдота
+func translateCallbackData(id string) string {
+	return translateCallbackPrefix + id
+}
+
+// parseTranslateCallback извлекает ID анекдота из callback_data кнопки перевода
+func parseTranslateCallback(data string) (string, bool) {
+	id, ok := strings.CutPrefix(data, translateCallbackPrefix)
+	return id, ok && id != ""
+}
+
+type rememberedJoke struct {
+	id       string
+	joke     Joke
+	storedAt time.Time
+}
+
+// JokeMemory помнит выданные анекдоты по ID, чтобы каждая кнопка под
+// сообщением (перевод, оценка) относилась к своему анекдоту
+type JokeMemory struct {
+	size  int
+	ttl   time.Duration
+	store jokeMemoryStore
+	now   func() time.Time
+	// saves считает сохранения в store, чтобы чистить его раз в jokeMemoryPruneEvery вставок
+	saves atomic.Int64
+
+	mu    sync.Mutex
+	order *list.List
+	items map[string]*list.Element
+}
+
+// NewJokeMemory создаёт память на size анекдотов; store может быть nil
+func NewJokeMemory(size int, ttl time.Duration, store jokeMemoryStore) *JokeMemory {
+	return &JokeMemory{
+		size:  size,
+		ttl:   ttl,
+		store: store,
+		now:   time.Now,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// newJokeMemoryFromConfig создаёт память по настройкам, подставляя значения по умолчанию
+func newJokeMemoryFromConfig(cfg JokeMemoryConfig, storage *Storage) *JokeMemory {
+	size := cfg.Size
+	if size <= 0 {
+		size = defaultJokeMemorySize
+	}
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = defaultJokeMemoryTTL
+	}
+	var store jokeMemoryStore
+	if cfg.Persist {
+		if storage != nil {
+			store = storage
+		} else {
+			logger.Warn("telegram.joke_memory.persist включён, но storage.path не задан: анекдоты бота хранятся только в памяти")
+		}
+	}
+	return NewJokeMemory(size, ttl, store)
+}
+
+// Remember сохраняет анекдот и возвращает его ID
+func (m *JokeMemory) Remember(ctx context.Context, joke Joke) string {
+	id := jokeID(joke.Text)
+	m.put(id, joke)
+	if m.store != nil {
+		if err := m.store.SaveBotJoke(ctx, id, joke); err != nil {
+			logger.Errorf("Ошибка сохранения анекдота бота в хранилище: %v", err)
+		}
+		// Первое сохранение тоже чистит хранилище: там могли остаться записи с прошлого запуска
+		if m.saves.Add(1)%jokeMemoryPruneEvery == 1 {
+			if err := m.store.PruneBotJokes(ctx, m.now().Add(-m.ttl)); err != nil {
+				logger.Errorf("Ошибка очистки анекдотов бота в хранилище: %v", err)
+			}
+		}
+	}
+	return id
+}
+
+// Lookup возвращает анекдот по ID, если он ещё не устарел
+func (m *JokeMemory) Lookup(ctx context.Context, id string) (joke Joke, ok bool) {
+	defer func() { observeCache("joke_memory", ok) }()
+	if joke, ok := m.get(id); ok {
+		return joke, true
+	}
+	if m.store == nil {
+		return Joke{}, false
+	}
+	joke, ok, err := m.store.LoadBotJoke(ctx, id, m.now().Add(-m.ttl))
+	if err != nil {
+		logger.Errorf("Ошибка чтения анекдота бота из хранилища: %v", err)
+		return Joke{}, false
+	}
+	if ok {
+		m.put(id, joke)
+	}
+	return joke, ok
+}
+
+// Len возвращает число анекдотов в памяти
+func (m *JokeMemory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
+
+func (m *JokeMemory) get(id string) (Joke, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.items[id]
+	if !ok {
+		return Joke{}, false
+	}
+	item := el.Value.(*rememberedJoke)
+	if m.now().Sub(item.storedAt) > m.ttl {
+		m.order.Remove(el)
+		delete(m.items, id)
+		return Joke{}, false
+	}
+	m.order.MoveToFront(el)
+	return item.joke, true
+}
+
+func (m *JokeMemory) put(id string, joke Joke) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.items[id]; ok {
+		el.Value = &rememberedJoke{id: id, joke: joke, storedAt: m.now()}
+		m.order.MoveToFront(el)
+		return
+	}
+	m.items[id] = m.order.PushFront(&rememberedJoke{id: id, joke: joke, storedAt: m.now()})
+	for m.order.Len() > m.size {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.items, oldest.Value.(*rememberedJoke).id)
+	}
+}
+
+// LoadBotJoke возвращает выданный анекдот, сохранённый не раньше notBefore
+func (s *Storage) LoadBotJoke(ctx context.Context, id string, notBefore time.Time) (Joke, bool, error) {
+	var joke Joke
+	err := s.db.QueryRowContext(ctx,
+		`SELECT text, source, is_russian FROM bot_jokes WHERE id = ? AND created_at >= ?`,
+		id, notBefore.Unix(),
+	).Scan(&joke.Text, &joke.Source, &joke.IsRussian)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Joke{}, false, nil
+	}
+	if err != nil {
+		return Joke{}, false, err
+	}
+	return joke, true, nil
+}
+
+// SaveBotJoke сохраняет выданный анекдот
+func (s *Storage) SaveBotJoke(ctx context.Context, id string, joke Joke) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO bot_jokes (id, text, source, is_russian, created_at) VALUES (?, ?, ?, ?, ?)`,
+		id, joke.Text, joke.Source, joke.IsRussian, time.Now().Unix())
+	return err
+}
+
+// PruneBotJokes удаляет анекдоты бота, сохранённые раньше notBefore
+func (s *Storage) PruneBotJokes(ctx context.Context, notBefore time.Time) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM bot_jokes WHERE created_at < ?`, notBefore.Unix())
+	return err
+}
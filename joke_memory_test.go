@@ -0,0 +1,130 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJokeMemory_RememberAndLookup(t *testing.T) {
+	memory := NewJokeMemory(10, time.Hour, nil)
+	ctx := context.Background()
+	first := memory.Remember(ctx, Joke{Text: "first joke"})
+	second := memory.Remember(ctx, Joke{Text: "second joke"})
+	if first == second {
+		t.Fatal("different jokes got the same ID")
+	}
+	if len(translateCallbackData(first)) > 64 {
+		t.Errorf("callback data %q exceeds Telegram limit", translateCallbackData(first))
+	}
+	if joke, ok := memory.Lookup(ctx, first); !ok || joke.Text != "first joke" {
+		t.Errorf("Lookup(first) = %+v, %v", joke, ok)
+	}
+	if _, ok := memory.Lookup(ctx, "unknown"); ok {
+		t.Error("unexpected joke for unknown ID")
+	}
+}
+
+func TestJokeMemory_SizeAndTTL(t *testing.T) {
+	memory := NewJokeMemory(2, time.Minute, nil)
+	now := time.Now()
+	memory.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	oldest := memory.Remember(ctx, Joke{Text: "joke 1"})
+	memory.Remember(ctx, Joke{Text: "joke 2"})
+	memory.Remember(ctx, Joke{Text: "joke 3"})
+	if memory.Len() != 2 {
+		t.Errorf("expected 2 jokes in memory, got %d", memory.Len())
+	}
+	if _, ok := memory.Lookup(ctx, oldest); ok {
+		t.Error("expected the oldest joke to be evicted")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := memory.Lookup(ctx, jokeID("joke 3")); ok {
+		t.Error("expected expired joke to be forgotten")
+	}
+}
+
+func TestJokeMemory_Persistent(t *testing.T) {
+	storage := openTestStorage(t)
+	ctx := context.Background()
+	joke := Joke{Text: "persisted joke", Source: "jokeapi.dev"}
+	id := NewJokeMemory(10, time.Hour, storage).Remember(ctx, joke)
+
+	// Новая память (например, после перезапуска) берёт анекдот из хранилища
+	got, ok := NewJokeMemory(10, time.Hour, storage).Lookup(ctx, id)
+	if !ok || got != joke {
+		t.Errorf("Lookup after restart = %+v, %v", got, ok)
+	}
+}
+
+// countingJokeStore считает обращения к хранилищу анекдотов бота
+type countingJokeStore struct {
+	saves, prunes int
+}
+
+func (s *countingJokeStore) LoadBotJoke(context.Context, string, time.Time) (Joke, bool, error) {
+	return Joke{}, false, nil
+}
+
+func (s *countingJokeStore) SaveBotJoke(context.Context, string, Joke) error {
+	s.saves++
+	return nil
+}
+
+func (s *countingJokeStore) PruneBotJokes(context.Context, time.Time) error {
+	s.prunes++
+	return nil
+}
+
+func TestJokeMemory_PrunesStorePeriodically(t *testing.T) {
+	store := &countingJokeStore{}
+	memory := NewJokeMemory(10, time.Hour, store)
+	for i := 0; i < jokeMemoryPruneEvery+1; i++ {
+		memory.Remember(context.Background(), Joke{Text: fmt.Sprintf("joke %d", i)})
+	}
+	if store.saves != jokeMemoryPruneEvery+1 || store.prunes != 2 {
+		t.Errorf("saves = %d, prunes = %d; want %d and 2", store.saves, store.prunes, jokeMemoryPruneEvery+1)
+	}
+}
+
+func TestStorage_PruneBotJokes(t *testing.T) {
+	storage := openTestStorage(t)
+	ctx := context.Background()
+	if err := storage.SaveBotJoke(ctx, "old", Joke{Text: "old joke"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.PruneBotJokes(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := storage.LoadBotJoke(ctx, "old", time.Time{}); ok {
+		t.Error("expected the outdated joke to be pruned")
+	}
+}
+
+func TestJokeMemory_Concurrent(t *testing.T) {
+	memory := NewJokeMemory(50, time.Hour, nil)
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				id := memory.Remember(ctx, Joke{Text: fmt.Sprintf("joke %d-%d", i, j)})
+				memory.Lookup(ctx, id)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if memory.Len() != 50 {
+		t.Errorf("expected memory to be bounded by 50, got %d", memory.Len())
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Форматы логов
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// requestIDHeader — заголовок с ID запроса; принимается от клиента и возвращается в ответе
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength ограничивает длину ID запроса, пришедшего от клиента
+const maxRequestIDLength = 128
+
+// LogConfig описывает уровень и формат логов
+type LogConfig struct {
+	// Level — уровень логирования: trace, debug, info, warn, error (по умолчанию info)
+	Level string `yaml:"level"`
+	// Format — text для чтения глазами или json для системы сбора логов (по умолчанию text)
+	Format string `yaml:"format"`
+}
+
+func (c LogConfig) validate() error {
+	if c.Level != "" {
+		if _, err := logrus.ParseLevel(c.Level); err != nil {
+			return fmt.Errorf("некорректный log.level %q", c.Level)
+		}
+	}
+	switch c.Format {
+	case "", logFormatText, logFormatJSON:
+		return nil
+	}
+	return fmt.Errorf("некорректный log.format %q: допустимы text и json", c.Format)
+}
+
+// configureLogger настраивает глобальный логгер по cfg
+func configureLogger(cfg LogConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	level := logrus.InfoLevel
+	if cfg.Level != "" {
+		level, _ = logrus.ParseLevel(cfg.Level)
+	}
+	logger.SetLevel(level)
+	if cfg.Format == logFormatJSON {
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	} else {
+		logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	}
+	logger.SetOutput(os.Stderr)
+	return nil
+}
+
+type requestIDKey struct{}
+
+// withRequestID сохраняет ID запроса в контексте
+func withRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// requestIDFromContext возвращает ID запроса из контекста или пустую строку
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// logFor возвращает запись лога с ID запроса и ID трассы из ctx, если они есть
+func logFor(ctx context.Context) *logrus.Entry {
+	fields := logrus.Fields{}
+	if id := requestIDFromContext(ctx); id != "" {
+		fields["request_id"] = id
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		fields["trace_id"] = span.TraceID().String()
+	}
+	return logger.WithFields(fields)
+}
+
+// newRequestID генерирует случайный ID запроса
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID проверяет ID от клиента: непустой, не длиннее maxRequestIDLength
+// и без пробелов и управляющих символов, чтобы его можно было безопасно логировать
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// requestIDMiddleware берёт ID запроса из X-Request-ID (или генерирует новый),
+// возвращает его в ответе и кладёт в контекст запроса для логов
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(withRequestID(r.Context(), id)))
+	})
+}
@@ -0,0 +1,141 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+// captureLogs перехватывает записи глобального логгера до конца теста
+func captureLogs(t *testing.T, level logrus.Level) *test.Hook {
+	t.Helper()
+	oldLevel := logger.GetLevel()
+	logger.SetLevel(level)
+	hook := test.NewLocal(logger)
+	t.Cleanup(func() {
+		logger.ReplaceHooks(make(logrus.LevelHooks))
+		logger.SetLevel(oldLevel)
+	})
+	return hook
+}
+
+func TestLogConfig_Validate(t *testing.T) {
+	valid := []LogConfig{{}, {Level: "debug", Format: "json"}, {Level: "WARN", Format: "text"}}
+	for _, cfg := range valid {
+		if err := cfg.validate(); err != nil {
+			t.Errorf("validate(%+v) = %v, want nil", cfg, err)
+		}
+	}
+	invalid := []LogConfig{{Level: "verbose"}, {Format: "xml"}}
+	for _, cfg := range invalid {
+		if err := cfg.validate(); err == nil {
+			t.Errorf("validate(%+v) = nil, want error", cfg)
+		}
+	}
+}
+
+func TestConfigureLogger_JSON(t *testing.T) {
+	oldLevel, oldFormatter, oldOut := logger.GetLevel(), logger.Formatter, logger.Out
+	defer func() {
+		logger.SetLevel(oldLevel)
+		logger.SetFormatter(oldFormatter)
+		logger.SetOutput(oldOut)
+	}()
+	if err := configureLogger(LogConfig{Level: "warn", Format: "json"}); err != nil {
+		t.Fatal(err)
+	}
+	if logger.GetLevel() != logrus.WarnLevel {
+		t.Errorf("level = %v, want warn", logger.GetLevel())
+	}
+	var buf bytes.Buffer
+	logger.SetOutput(&buf)
+	logFor(withRequestID(context.Background(), "abc")).Warn("проверка")
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log line is not JSON: %q", buf.String())
+	}
+	if entry["request_id"] != "abc" || entry["msg"] != "проверка" || entry["level"] != "warning" {
+		t.Errorf("entry = %v", entry)
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var seen string
+	handler := requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestIDFromContext(r.Context())
+	}))
+
+	cases := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"from client", "req-42", true},
+		{"missing", "", false},
+		{"with spaces", "a b", false},
+		{"too long", strings.Repeat("x", maxRequestIDLength+1), false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/random-joke", nil)
+			if tc.header != "" {
+				req.Header.Set(requestIDHeader, tc.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if seen == "" || w.Header().Get(requestIDHeader) != seen {
+				t.Fatalf("context ID %q, response header %q", seen, w.Header().Get(requestIDHeader))
+			}
+			if (seen == tc.header) != tc.keep {
+				t.Errorf("request ID = %q, header %q, keep = %v", seen, tc.header, tc.keep)
+			}
+		})
+	}
+}
+
+func TestProviderLogs_RequestIDWithoutJokeText(t *testing.T) {
+	const text = "Секретный анекдот"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<meta name="description" content="` + text + `">`))
+	}))
+	defer server.Close()
+
+	hook := captureLogs(t, logrus.InfoLevel)
+	ctx := withRequestID(context.Background(), "req-1")
+	joke, err := BaneksProvider{BaseURL: server.URL}.FetchJoke(ctx)
+	if err != nil || joke.Text != text {
+		t.Fatalf("FetchJoke() = %+v, %v", joke, err)
+	}
+	if len(hook.AllEntries()) == 0 {
+		t.Fatal("no log entries at info level")
+	}
+	for _, entry := range hook.AllEntries() {
+		if entry.Data["request_id"] != "req-1" {
+			t.Errorf("entry %q has no request_id", entry.Message)
+		}
+		if strings.Contains(entry.Message, text) {
+			t.Errorf("joke text logged at %v: %q", entry.Level, entry.Message)
+		}
+	}
+
+	hook.Reset()
+	logger.SetLevel(logrus.DebugLevel)
+	BaneksProvider{BaseURL: server.URL}.FetchJoke(ctx)
+	logged := false
+	for _, entry := range hook.AllEntries() {
+		logged = logged || (entry.Level == logrus.DebugLevel && strings.Contains(entry.Message, text))
+	}
+	if !logged {
+		t.Error("joke text not logged at debug level")
+	}
+}
@@ -6,22 +6,21 @@ import (
 	"encoding/json"
 	"flag"
 	"io"
-	"math/rand"
 	"net/http"
-	"net/url"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/gorilla/mux"
-	"github.com/sirupsen/logrus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/net/html/charset"
 )
 
 var (
-	port = flag.String("port", "8888", "Порт для запуска сервера")
+	port      = flag.String("port", "8888", "Порт для запуска сервера")
+	logLevel  = flag.String("log-level", "", "Уровень логирования (переопределяет log.level из config.yaml)")
+	logFormat = flag.String("log-format", "", "Формат логов: text или json (переопределяет log.format из config.yaml)")
 )
 
 func main() {
@@ -34,29 +33,137 @@ func main() {
 	}
 	appConfig = config
 
-	// Set up logging
-	logger.SetFormatter(&logrus.TextFormatter{
-		FullTimestamp: true,
-		DisableColors: false,
-	})
-	logger.SetLevel(logrus.DebugLevel)
-	logger.SetOutput(os.Stderr)
+	// Настраиваем логи до остальных компонентов, чтобы их сообщения попали в нужный формат
+	if *logLevel != "" {
+		config.Log.Level = *logLevel
+	}
+	if *logFormat != "" {
+		config.Log.Format = *logFormat
+	}
+	if err := configureLogger(config.Log); err != nil {
+		logger.Fatalf("Ошибка настройки логов: %v", err)
+	}
+
+	shutdownTracing, err := setupTracing(context.Background(), config.Tracing)
+	if err != nil {
+		logger.Fatalf("Ошибка настройки трассировки: %v", err)
+	}
+	if config.Tracing.Enabled() {
+		logger.Infof("Трассировка включена, экспорт: %s", config.Tracing.Exporter)
+	}
+
+	// Общий HTTP-клиент для провайдеров и переводчиков
+	client, err := NewHTTPClient(config.HTTPClient)
+	if err != nil {
+		logger.Fatalf("Ошибка настройки HTTP-клиента: %v", err)
+	}
+	upstreamClient = client
+
+	registry, err := newProviderRegistryFromConfig(config.Providers, client)
+	if err != nil {
+		logger.Fatalf("Ошибка настройки провайдеров: %v", err)
+	}
+	providerRegistry = registry
+
+	// Архив анекдотов в SQLite (необязательно)
+	var storage *Storage
+	if config.Storage.Enabled() {
+		storage, err = OpenStorage(config.Storage.Path)
+		if err != nil {
+			logger.Fatalf("Ошибка открытия хранилища: %v", err)
+		}
+		providerRegistry.Wrap(func(p JokeProvider) JokeProvider {
+			return NewArchivingProvider(p, storage)
+		})
+		providerRegistry.SetFallback(NewArchiveProvider(storage))
+		logger.Infof("Архив анекдотов: %s", config.Storage.Path)
+	}
+
+	recentJokes = newRecentJokesFromConfig(config.Dedup)
+
+	backend, err := newTranslatorFromConfig(config.Translation, client)
+	if err != nil {
+		logger.Fatalf("Ошибка настройки перевода: %v", err)
+	}
+	// Метрики считают только обращения к бэкенду, попадания в кэш учитываются отдельно
+	translator = newTranslationCacheFromConfig(NewMeteredTranslator(backend), config.Translation.Cache, storage)
+	jokeMemory = newJokeMemoryFromConfig(config.Telegram.JokeMemory, storage)
+
+	// Оценки анекдотов сохраняются в хранилище и влияют на веса провайдеров
+	var votes ratingStore
+	if storage != nil {
+		votes = storage
+	}
+	jokeRatings, err = NewRatings(votes)
+	if err != nil {
+		logger.Fatalf("Ошибка загрузки оценок: %v", err)
+	}
+	providerRegistry.SetWeightFactor(jokeRatings.WeightFactor)
+
+	// Настройки чатов хранятся вместе с остальными данными бота
+	if storage != nil {
+		chatSettings = NewChatSettingsRegistry(storage)
+	}
+
+	// Фоновая предзагрузка анекдотов
+	var prefetchers []*PrefetchProvider
+	if config.Prefetch.Enabled {
+		providerRegistry.Wrap(func(p JokeProvider) JokeProvider {
+			prefetcher := NewPrefetchProvider(p, config.Prefetch)
+			prefetchers = append(prefetchers, prefetcher)
+			return prefetcher
+		})
+		// Реестр подписывается на запросы предзагрузки при Wrap, поэтому запуск — после него
+		for _, prefetcher := range prefetchers {
+			prefetcher.Start()
+		}
+		logger.Infof("Включена предзагрузка анекдотов для %d провайдеров", len(prefetchers))
+	}
+
+	// Фоновая проверка провайдеров для /readyz
+	prober := NewHealthProber(providerRegistry, config.Health)
+	prober.Start()
+
+	// Telegram-бот создаётся один раз и используется для всех обновлений
+	stopTelegram := func(context.Context) {}
+	if config.TelegramBotToken != "" {
+		subscriptions, err = newSubscriptionSchedulerFromConfig(config.Telegram.Subscriptions, storage)
+		if err != nil {
+			logger.Fatalf("Ошибка настройки подписок: %v", err)
+		}
+		stopTelegram = startTelegramBot(config.TelegramBotToken, config.Telegram)
+	} else {
+		logger.Warn("telegram_bot_token не задан, Telegram-бот отключён")
+	}
 
 	router := mux.NewRouter()
 	// Подключаем middleware
+	router.Use(requestIDMiddleware)
+	router.Use(tracingMiddleware)
 	router.Use(loggingMiddleware)
 	router.Use(corsMiddleware)
 
 	// Регистрируем маршруты
 	router.HandleFunc("/random-joke", getRandomJoke).Methods("GET")
+	router.HandleFunc("/jokes", getJokesBatch).Methods("GET")
+	router.HandleFunc("/jokes/stream", streamJokes).Methods("GET")
+	router.HandleFunc("/jokes/top", getTopJokes).Methods("GET")
+	router.HandleFunc("/jokes/{id}/vote", voteJokeHandler).Methods("POST")
 	router.HandleFunc("/translate", translateHandler).Methods("POST")
-	router.HandleFunc("/telegram-webhook", telegramWebhookHandler).Methods("POST")
+	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	router.HandleFunc("/healthz", healthzHandler).Methods("GET")
+	router.HandleFunc("/readyz", readyzHandler).Methods("GET")
+	if !config.Telegram.IsPolling() {
+		router.HandleFunc("/telegram-webhook", telegramWebhookHandler).Methods("POST")
+	}
 	router.PathPrefix("/").HandlerFunc(spaHandler)
 
 	srv := &http.Server{
 		Addr:    ":" + *port,
 		Handler: router,
 	}
+	// Потоки SSE не завершаются сами, закрываем их при остановке сервера
+	srv.RegisterOnShutdown(jokeHub.Close)
 
 	// Канал для получения сигналов операционной системы
 	done := make(chan os.Signal, 1)
@@ -83,6 +190,21 @@ func main() {
 		os.Exit(1)
 	}
 
+	stopTelegram(ctx)
+	prober.Stop()
+	for _, prefetcher := range prefetchers {
+		prefetcher.Stop()
+	}
+	if storage != nil {
+		if err := storage.Close(); err != nil {
+			logger.Errorf("Ошибка закрытия хранилища: %v", err)
+		}
+	}
+	// Трассировка останавливается последней, чтобы выгрузить спаны остановки
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Errorf("Ошибка выгрузки трасс: %v", err)
+	}
+
 	logger.Info("Сервер успешно остановлен")
 }
 
@@ -103,24 +225,36 @@ func spaHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func getRandomJoke(w http.ResponseWriter, r *http.Request) {
-	provider := selectWeightedProvider()
-	logger.Infof("Выбран провайдер: %T", provider)
+	filter, err := parseJokeFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !providerRegistry.Matches(filter) {
+		http.Error(w, "Нет источников, подходящих под фильтр", http.StatusNotFound)
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 	defer cancel()
 
-	joke, err := provider.FetchJoke(ctx)
+	joke, err := recentJokes.FetchUnique(sessionClientKey(w, r), func() (Joke, error) {
+		return providerRegistry.FetchFilteredJoke(ctx, filter)
+	})
 	if err != nil {
-		logger.Errorf("Ошибка получения анекдота от провайдера %T: %v", provider, err)
+		logFor(r.Context()).Errorf("Ошибка получения анекдота: %v", err)
 		http.Error(w, "Анекдоты временно недоступны", http.StatusInternalServerError)
 		return
 	}
 
-	logger.Infof("Получен анекдот от %s: %s", joke.Source, joke.Text)
+	logFor(r.Context()).Infof("Получен анекдот от %s", joke.Source)
+	logFor(r.Context()).Debugf("Текст анекдота: %s", joke.Text)
+	// Запоминаем анекдот, чтобы за него можно было проголосовать
+	webJokes.Remember(r.Context(), joke)
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(joke); err != nil {
-		logger.Errorf("Ошибка сериализации анекдота в JSON: %v", err)
+		logFor(r.Context()).Errorf("Ошибка сериализации анекдота в JSON: %v", err)
 		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
 		return
 	}
@@ -140,10 +274,12 @@ func decodeWindows1251(body []byte) ([]byte, error) {
 	return io.ReadAll(reader)
 }
 
-// translateHandler переводит текст анекдота на русский язык через Google Translate proxy
+// translateHandler переводит текст анекдота (по умолчанию с английского на русский)
 func translateHandler(w http.ResponseWriter, r *http.Request) {
 	type reqBody struct {
-		Text string `json:"text"`
+		Text   string `json:"text"`
+		Source string `json:"source"`
+		Target string `json:"target"`
 	}
 	type respBody struct {
 		Translation string `json:"translation"`
@@ -153,184 +289,47 @@ func translateHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Некорректный запрос", http.StatusBadRequest)
 		return
 	}
+	source, target := orDefault(body.Source, "en"), orDefault(body.Target, "ru")
+	if !validLangCode(source) || !validLangCode(target) || target == "auto" {
+		http.Error(w, "Некорректный код языка", http.StatusBadRequest)
+		return
+	}
 	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
 	defer cancel()
 
-	// Формируем URL для Google Translate proxy
-	url := "https://translate.googleapis.com/translate_a/single?client=gtx&sl=en&tl=ru&dt=t&q=" + urlQueryEscape(body.Text)
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		logger.Errorf("Ошибка создания запроса к Google Translate: %v", err)
-		http.Error(w, "Ошибка перевода", http.StatusInternalServerError)
-		return
-	}
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	translation, err := translator.Translate(ctx, body.Text, source, target)
 	if err != nil {
-		logger.Errorf("Ошибка обращения к Google Translate: %v", err)
+		logFor(r.Context()).Errorf("Ошибка перевода через %s: %v", translator.Name(), err)
 		http.Error(w, "Ошибка перевода", http.StatusInternalServerError)
 		return
 	}
-	defer resp.Body.Close()
-	var data interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		logger.Errorf("Ошибка декодирования ответа Google Translate: %v", err)
-		http.Error(w, "Ошибка перевода", http.StatusInternalServerError)
-		return
-	}
-	// Извлекаем перевод из data
-	translation := ""
-	if arr, ok := data.([]interface{}); ok && len(arr) > 0 {
-		if innerArr, ok := arr[0].([]interface{}); ok {
-			for _, seg := range innerArr {
-				if segArr, ok := seg.([]interface{}); ok && len(segArr) > 0 {
-					if str, ok := segArr[0].(string); ok {
-						translation += str
-					}
-				}
-			}
-		}
-	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(respBody{Translation: translation})
 }
 
-// urlQueryEscape экранирует строку для URL-параметра
-func urlQueryEscape(s string) string {
-	return (&url.URL{Path: s}).EscapedPath()[1:]
+// fetchRandomJoke возвращает случайный анекдот (используется ботом; срок задаёт ctx)
+func fetchRandomJoke(ctx context.Context) (Joke, error) {
+	return providerRegistry.FetchJoke(ctx)
 }
 
-// fetchRandomJoke возвращает случайный анекдот (используется ботом)
-func fetchRandomJoke() (Joke, error) {
-	rand.Seed(time.Now().UnixNano())
-	provider := jokeProviders[rand.Intn(len(jokeProviders))]
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-	return provider.FetchJoke(ctx)
+// fetchRussianJoke возвращает анекдот на русском (используется ботом; срок задаёт ctx)
+func fetchRussianJoke(ctx context.Context) (Joke, error) {
+	return providerRegistry.FetchFilteredJoke(ctx, JokeFilter{Lang: langRussian})
 }
 
-// fetchRzhunemoguJoke возвращает анекдот с rzhunemogu.ru (используется ботом)
-func fetchRzhunemoguJoke() (Joke, error) {
-	provider := RzhunemoguProvider{}
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-	return provider.FetchJoke(ctx)
-}
-
-// translateText переводит текст анекдота на русский язык через Google Translate proxy
-func translateText(text string) (string, error) {
-	url := "https://translate.googleapis.com/translate_a/single?client=gtx&sl=en&tl=ru&dt=t&q=" + urlQueryEscape(text)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return "", err
-	}
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	var data interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return "", err
-	}
-	translation := ""
-	if arr, ok := data.([]interface{}); ok && len(arr) > 0 {
-		if innerArr, ok := arr[0].([]interface{}); ok {
-			for _, seg := range innerArr {
-				if segArr, ok := seg.([]interface{}); ok && len(segArr) > 0 {
-					if str, ok := segArr[0].(string); ok {
-						translation += str
-					}
-				}
-			}
-		}
-	}
-	return translation, nil
+// fetchEnglishJoke возвращает анекдот на английском (используется ботом; срок задаёт ctx)
+func fetchEnglishJoke(ctx context.Context) (Joke, error) {
+	return providerRegistry.FetchFilteredJoke(ctx, JokeFilter{Lang: langEnglish})
 }
 
-// telegramWebhookHandler обрабатывает входящие webhook-запросы Telegram
-func telegramWebhookHandler(w http.ResponseWriter, r *http.Request) {
-	bot, err := tgbotapi.NewBotAPI(appConfig.TelegramBotToken)
-	if err != nil {
-		logger.Errorf("Ошибка запуска Telegram-бота: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	update := tgbotapi.Update{}
-	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
-		logger.Errorf("Ошибка декодирования webhook: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
-	processTelegramUpdate(bot, update)
-	w.WriteHeader(http.StatusOK)
+// fetchFilteredJoke возвращает анекдот, подходящий под фильтр (используется ботом; срок задаёт ctx)
+func fetchFilteredJoke(ctx context.Context, filter JokeFilter) (Joke, error) {
+	return providerRegistry.FetchFilteredJoke(ctx, filter)
 }
 
-// processTelegramUpdate обрабатывает update (логика Telegram-бота)
-func processTelegramUpdate(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
-	if update.CallbackQuery != nil && update.CallbackQuery.Data == "translate_joke" {
-		chatID := update.CallbackQuery.Message.Chat.ID
-		callback := tgbotapi.NewCallback(update.CallbackQuery.ID, "Переведено")
-		bot.Request(callback)
-		edit := tgbotapi.NewEditMessageReplyMarkup(chatID, update.CallbackQuery.Message.MessageID, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}})
-		bot.Send(edit)
-		if jokeMemory != nil {
-			if jokeText, ok := jokeMemory[chatID]; ok {
-				translation, err := translateText(jokeText)
-				if err != nil || translation == "" {
-					msg := tgbotapi.NewMessage(chatID, "Ошибка перевода")
-					bot.Send(msg)
-				} else {
-					msg := tgbotapi.NewMessage(chatID, translation)
-					bot.Send(msg)
-				}
-			}
-		}
-		return
-	}
-	if update.Message == nil {
-		return
-	}
-	if update.Message.IsCommand() {
-		switch update.Message.Command() {
-		case "start":
-			msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Привет! Я бот-анекдотчик 🤖\n\nЯ умею присылать случайные анекдоты из разных источников. Просто отправь команду /joke, чтобы получить свежий анекдот!\n\nТакже я могу переводить анекдоты на русский язык, если потребуется.\n\nПиши /joke — и улыбка гарантирована!")
-			bot.Send(msg)
-		case "joke":
-			joke, err := fetchRandomJoke()
-			if err != nil {
-				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Анекдоты временно недоступны")
-				bot.Send(msg)
-				return
-			}
-			msg := tgbotapi.NewMessage(update.Message.Chat.ID, joke.Text)
-			if !joke.IsRussian {
-				keyboard := tgbotapi.NewInlineKeyboardMarkup(
-					tgbotapi.NewInlineKeyboardRow(
-						tgbotapi.NewInlineKeyboardButtonData("Перевести на русский", "translate_joke"),
-					),
-				)
-				msg.ReplyMarkup = keyboard
-				if jokeMemory == nil {
-					jokeMemory = make(map[int64]string)
-				}
-				jokeMemory[update.Message.Chat.ID] = joke.Text
-			}
-			bot.Send(msg)
-		case "joke_ru":
-			joke, err := fetchRzhunemoguJoke()
-			if err != nil {
-				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Русские анекдоты временно недоступны")
-				bot.Send(msg)
-				return
-			}
-			msg := tgbotapi.NewMessage(update.Message.Chat.ID, joke.Text)
-			bot.Send(msg)
-		default:
-			msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Используйте /joke для получения случайного анекдота.")
-			bot.Send(msg)
-		}
-	}
+// translateText переводит текст анекдота на русский язык (используется ботом)
+func translateText(text string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+	defer cancel()
+	return translator.Translate(ctx, text, "en", "ru")
 }
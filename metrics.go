@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// metricsNamespace — префикс имён метрик сервиса
+const metricsNamespace = "joke_service"
+
+// Метрики Prometheus, отдаются на /metrics
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP-запросы по маршруту, методу и статусу ответа.",
+	}, []string{"route", "method", "status"})
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Время обработки HTTP-запросов.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	providerRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "provider_requests_total",
+		Help:      "Запросы анекдотов к провайдерам по результату (success или failure).",
+	}, []string{"provider", "result"})
+	providerRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "provider_request_duration_seconds",
+		Help:      "Время ответа провайдеров анекдотов.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"provider"})
+
+	translationRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "translation_requests_total",
+		Help:      "Обращения к бэкенду перевода по результату (success или failure), без попаданий в кэш.",
+	}, []string{"backend", "result"})
+	translationRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "translation_request_duration_seconds",
+		Help:      "Время ответа бэкенда перевода.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"backend"})
+
+	cacheRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cache_requests_total",
+		Help:      "Обращения к кэшам (translation, joke_memory, prefetch) по результату: hit или miss.",
+	}, []string{"cache", "result"})
+
+	outboundRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "outbound_http_requests_total",
+		Help:      "Попытки исходящих HTTP-запросов к провайдерам и переводчикам по хосту и статусу ответа (error — без ответа).",
+	}, []string{"host", "status"})
+	outboundRetriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "outbound_http_retries_total",
+		Help:      "Повторы исходящих HTTP-запросов по хосту.",
+	}, []string{"host"})
+
+	telegramUpdatesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "telegram_updates_total",
+		Help:      "Обновления Telegram по типу.",
+	}, []string{"type"})
+	telegramMessagesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "telegram_messages_total",
+		Help:      "Исходящие сообщения Telegram по результату: sent, retried, failed, dropped или blocked.",
+	}, []string{"result"})
+)
+
+// observeHTTPRequest учитывает обработанный HTTP-запрос
+func observeHTTPRequest(route, method string, status int, duration time.Duration) {
+	httpRequestsTotal.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	httpRequestDuration.WithLabelValues(route, method).Observe(duration.Seconds())
+}
+
+// observeProviderFetch учитывает запрос анекдота к провайдеру
+func observeProviderFetch(provider string, latency time.Duration, err error) {
+	providerRequestsTotal.WithLabelValues(provider, metricsResult(err)).Inc()
+	providerRequestDuration.WithLabelValues(provider).Observe(latency.Seconds())
+}
+
+// observeCache учитывает попадание или промах кэша
+func observeCache(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheRequestsTotal.WithLabelValues(cache, result).Inc()
+}
+
+// observeOutboundRequest учитывает попытку исходящего HTTP-запроса
+func observeOutboundRequest(host string, resp *http.Response, err error) {
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	outboundRequestsTotal.WithLabelValues(host, status).Inc()
+}
+
+// observeOutboundRetry учитывает повтор исходящего HTTP-запроса
+func observeOutboundRetry(host string) {
+	outboundRetriesTotal.WithLabelValues(host).Inc()
+}
+
+// observeTelegramUpdate учитывает входящее обновление Telegram
+func observeTelegramUpdate(update tgbotapi.Update) {
+	telegramUpdatesTotal.WithLabelValues(telegramUpdateType(update)).Inc()
+}
+
+// observeTelegramMessages учитывает n исходящих сообщений Telegram с результатом result
+func observeTelegramMessages(result string, n int64) {
+	telegramMessagesTotal.WithLabelValues(result).Add(float64(n))
+}
+
+// telegramUpdateType возвращает тип обновления для метрик
+func telegramUpdateType(update tgbotapi.Update) string {
+	switch {
+	case update.Message != nil && update.Message.IsCommand():
+		return "command"
+	case update.Message != nil:
+		return "message"
+	case update.CallbackQuery != nil:
+		return "callback_query"
+	case update.InlineQuery != nil:
+		return "inline_query"
+	case update.MyChatMember != nil:
+		return "my_chat_member"
+	}
+	return "other"
+}
+
+func metricsResult(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// MeteredTranslator считает обращения к бэкенду перевода и их время и трассирует их
+type MeteredTranslator struct {
+	inner Translator
+}
+
+// NewMeteredTranslator оборачивает бэкенд перевода метриками
+func NewMeteredTranslator(inner Translator) *MeteredTranslator {
+	return &MeteredTranslator{inner: inner}
+}
+
+func (t *MeteredTranslator) Name() string {
+	return t.inner.Name()
+}
+
+func (t *MeteredTranslator) Translate(ctx context.Context, text, source, target string) (string, error) {
+	ctx, span := startSpan(ctx, "translation.backend", trace.WithAttributes(
+		attribute.String("translation.backend", t.Name()),
+		attribute.String("translation.source", source),
+		attribute.String("translation.target", target),
+	))
+	start := time.Now()
+	translation, err := t.inner.Translate(ctx, text, source, target)
+	latency := time.Since(start)
+	endSpan(span, err)
+	translationRequestsTotal.WithLabelValues(t.Name(), metricsResult(err)).Inc()
+	translationRequestDuration.WithLabelValues(t.Name()).Observe(latency.Seconds())
+	logFor(ctx).Debugf("Перевод через %s (%s → %s) за %s", t.Name(), source, target, latency)
+	return translation, err
+}
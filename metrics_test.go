@@ -0,0 +1,105 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestLoggingMiddleware_Metrics(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(loggingMiddleware)
+	router.HandleFunc("/jokes/{id}/vote", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Анекдот не найден", http.StatusNotFound)
+	}).Methods("POST")
+	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
+
+	requests := httpRequestsTotal.WithLabelValues("/jokes/{id}/vote", "POST", "404")
+	before := testutil.ToFloat64(requests)
+	for _, id := range []string{"a1", "b2"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/jokes/"+id+"/vote", nil))
+	}
+	if got := testutil.ToFloat64(requests) - before; got != 2 {
+		t.Errorf("requests counted = %v, want 2 under one route template", got)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	for _, name := range []string{
+		`joke_service_http_requests_total{method="POST",route="/jokes/{id}/vote",status="404"}`,
+		"joke_service_http_request_duration_seconds_bucket",
+	} {
+		if !strings.Contains(body, name) {
+			t.Errorf("/metrics does not contain %s", name)
+		}
+	}
+}
+
+func TestProviderRegistry_Metrics(t *testing.T) {
+	registry := NewProviderRegistry(
+		ProviderSpec{Provider: failingProvider("metrics-bad"), Weight: 1000},
+		ProviderSpec{Provider: okProvider("metrics-good"), Weight: 1},
+	)
+	if _, err := registry.FetchJoke(context.Background()); err != nil {
+		t.Fatalf("FetchJoke error: %v", err)
+	}
+	if got := testutil.ToFloat64(providerRequestsTotal.WithLabelValues("metrics-good", "success")); got != 1 {
+		t.Errorf("successes = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(providerRequestsTotal.WithLabelValues("metrics-bad", "failure")); got < 1 {
+		t.Errorf("failures = %v, want at least 1", got)
+	}
+}
+
+func TestTranslationMetrics(t *testing.T) {
+	translations := translationRequestsTotal.WithLabelValues(translatorDictionary, "success")
+	hits := cacheRequestsTotal.WithLabelValues("translation", "hit")
+	misses := cacheRequestsTotal.WithLabelValues("translation", "miss")
+	beforeCalls, beforeHits, beforeMisses := testutil.ToFloat64(translations), testutil.ToFloat64(hits), testutil.ToFloat64(misses)
+
+	cached := NewCachingTranslator(NewMeteredTranslator(DictionaryTranslator{}), 10, time.Hour, nil)
+	for i := 0; i < 3; i++ {
+		cached.Translate(context.Background(), "metrics joke", "en", "ru")
+	}
+	// Бэкенд вызывается один раз, остальные переводы берутся из кэша
+	if got := testutil.ToFloat64(translations) - beforeCalls; got != 1 {
+		t.Errorf("backend calls = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(hits) - beforeHits; got != 2 {
+		t.Errorf("cache hits = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(misses) - beforeMisses; got != 1 {
+		t.Errorf("cache misses = %v, want 1", got)
+	}
+}
+
+func TestTelegramUpdateType(t *testing.T) {
+	tests := []struct {
+		update tgbotapi.Update
+		want   string
+	}{
+		{commandUpdate(1, "joke"), "command"},
+		{tgbotapi.Update{Message: &tgbotapi.Message{Text: "привет"}}, "message"},
+		{tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{}}, "callback_query"},
+		{tgbotapi.Update{InlineQuery: &tgbotapi.InlineQuery{}}, "inline_query"},
+		{tgbotapi.Update{MyChatMember: &tgbotapi.ChatMemberUpdated{}}, "my_chat_member"},
+		{tgbotapi.Update{}, "other"},
+	}
+	for _, tt := range tests {
+		if got := telegramUpdateType(tt.update); got != tt.want {
+			t.Errorf("telegramUpdateType = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -3,6 +3,9 @@ package main
 import (
 	"net/http"
 	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
 )
 
 // responseWriter для отслеживания статус-кода
@@ -20,16 +23,39 @@ func (rw *responseWriter) Header() http.Header {
 	return rw.ResponseWriter.Header()
 }
 
-// loggingMiddleware логирует все HTTP-запросы
+// Unwrap позволяет http.ResponseController добраться до Flush исходного writer (нужно для SSE)
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// loggingMiddleware логирует все HTTP-запросы (с ID запроса) и учитывает их в метриках
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		rw := &responseWriter{w, http.StatusOK}
 		next.ServeHTTP(rw, r)
-		logger.Infof("[HTTP] %s %s %d %s", r.Method, r.URL.Path, rw.statusCode, time.Since(start))
+		duration := time.Since(start)
+		logFor(r.Context()).WithFields(logrus.Fields{
+			"method":   r.Method,
+			"path":     r.URL.Path,
+			"status":   rw.statusCode,
+			"duration": duration.String(),
+		}).Info("[HTTP] запрос обработан")
+		observeHTTPRequest(routeTemplate(r), r.Method, rw.statusCode, duration)
 	})
 }
 
+// routeTemplate возвращает шаблон маршрута (/jokes/{id}/vote), чтобы у метрик
+// не было отдельной серии на каждый ID
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unmatched"
+}
+
 // corsMiddleware добавляет CORS заголовки
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -41,7 +67,8 @@ func corsMiddleware(next http.Handler) http.Handler {
 			}
 		}
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+requestIDHeader)
+		w.Header().Set("Access-Control-Expose-Headers", requestIDHeader)
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 )
 
 // Joke представляет структуру анекдота
@@ -11,7 +12,18 @@ type Joke struct {
 	IsRussian bool   `json:"is_russian"`
 }
 
+// MarshalJSON добавляет к анекдоту id, по которому за него можно проголосовать
+func (j Joke) MarshalJSON() ([]byte, error) {
+	type joke Joke
+	return json.Marshal(struct {
+		joke
+		ID string `json:"id"`
+	}{joke(j), jokeID(j.Text)})
+}
+
 // JokeProvider описывает интерфейс для получения анекдота
 type JokeProvider interface {
+	// Name возвращает имя источника (совпадает с Joke.Source)
+	Name() string
 	FetchJoke(ctx context.Context) (Joke, error)
 }
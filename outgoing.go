@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Ограничения Telegram на отправку сообщений по умолчанию
+const (
+	defaultOutgoingGlobalRate   = 30
+	defaultOutgoingChatInterval = time.Second
+	defaultOutgoingMaxRetries   = 3
+	defaultOutgoingChatQueue    = 50
+
+	// maxOutboxes — после этого числа чатов очереди простаивающих чатов удаляются
+	maxOutboxes = 10000
+)
+
+// errOutgoingQueueFull возвращается, когда у чата накопилось слишком много неотправленных сообщений
+var errOutgoingQueueFull = errors.New("очередь сообщений чата переполнена")
+
+// errOutgoingQueueStopped возвращается после остановки очереди
+var errOutgoingQueueStopped = errors.New("очередь сообщений остановлена")
+
+// OutgoingConfig описывает очередь исходящих сообщений Telegram
+type OutgoingConfig struct {
+	// GlobalRate — сообщений в секунду на всех чатах (лимит Telegram — около 30)
+	GlobalRate int `yaml:"global_rate"`
+	// ChatInterval — минимальный интервал между сообщениями в один чат
+	ChatInterval time.Duration `yaml:"chat_interval"`
+	// MaxRetries — сколько раз повторять сообщение после ответа 429
+	MaxRetries int `yaml:"max_retries"`
+	// ChatQueueSize — максимум неотправленных сообщений одного чата
+	ChatQueueSize int `yaml:"chat_queue_size"`
+}
+
+func (c OutgoingConfig) validate() error {
+	if c.GlobalRate < 0 {
+		return fmt.Errorf("telegram.outgoing.global_rate не может быть отрицательным")
+	}
+	if c.ChatInterval < 0 {
+		return fmt.Errorf("telegram.outgoing.chat_interval не может быть отрицательным")
+	}
+	if c.MaxRetries < 0 {
+		return fmt.Errorf("telegram.outgoing.max_retries не может быть отрицательным")
+	}
+	if c.ChatQueueSize < 0 {
+		return fmt.Errorf("telegram.outgoing.chat_queue_size не может быть отрицательным")
+	}
+	return nil
+}
+
+// OutgoingStats — счётчики очереди исходящих сообщений
+type OutgoingStats struct {
+	Sent    int64 `json:"sent"`
+	Retried int64 `json:"retried"`
+	Failed  int64 `json:"failed"`
+	Dropped int64 `json:"dropped"`
+	Blocked int64 `json:"blocked"`
+}
+
+// chatOutbox — неотправленные сообщения одного чата
+type chatOutbox struct {
+	pending []tgbotapi.Chattable
+	next    time.Time // раньше этого момента в чат писать нельзя
+}
+
+// OutgoingQueue отправляет сообщения Telegram с учётом ограничений API: не больше
+// GlobalRate сообщений в секунду и одного сообщения в чат за ChatInterval.
+// Send ставит сообщение в очередь и сразу возвращается; ошибки постановки в
+// очередь и отправки логируются и учитываются в метриках, вызывающему их
+// обрабатывать не нужно. Request выполняется сразу — через него
+// отправляются ответы на callback и inline-запросы, которые не ждут.
+type OutgoingQueue struct {
+	sender  telegramSender
+	cfg     OutgoingConfig
+	blocked func(chatID int64)
+	now     func() time.Time
+	wait    func(ctx context.Context, d time.Duration) bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu         sync.Mutex
+	chats      map[int64]*chatOutbox
+	nextGlobal time.Time
+
+	sent, retried, failed, dropped, blockedChats atomic.Int64
+}
+
+// NewOutgoingQueue создаёт очередь поверх sender; blocked вызывается для чатов,
+// в которые бот больше не может писать (ответ 403), и может быть nil
+func NewOutgoingQueue(sender telegramSender, cfg OutgoingConfig, blocked func(chatID int64)) *OutgoingQueue {
+	if cfg.GlobalRate <= 0 {
+		cfg.GlobalRate = defaultOutgoingGlobalRate
+	}
+	if cfg.ChatInterval <= 0 {
+		cfg.ChatInterval = defaultOutgoingChatInterval
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = defaultOutgoingMaxRetries
+	}
+	if cfg.ChatQueueSize <= 0 {
+		cfg.ChatQueueSize = defaultOutgoingChatQueue
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &OutgoingQueue{
+		sender:  sender,
+		cfg:     cfg,
+		blocked: blocked,
+		now:     time.Now,
+		wait:    sleepContext,
+		ctx:     ctx,
+		cancel:  cancel,
+		chats:   make(map[int64]*chatOutbox),
+	}
+}
+
+// Send ставит сообщение в очередь его чата. Отправленное сообщение Send не
+// возвращает: оно уходит уже после возврата из Send.
+func (q *OutgoingQueue) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	chatID := chattableChatID(c)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.ctx.Err() != nil {
+		q.count(&q.dropped, "dropped", 1)
+		logger.Warnf("Сообщение в чат %d отброшено: %v", chatID, errOutgoingQueueStopped)
+		return tgbotapi.Message{}, errOutgoingQueueStopped
+	}
+	outbox, ok := q.chats[chatID]
+	if !ok {
+		if len(q.chats) >= maxOutboxes {
+			q.pruneLocked()
+		}
+		outbox = &chatOutbox{}
+		q.chats[chatID] = outbox
+	}
+	if len(outbox.pending) >= q.cfg.ChatQueueSize {
+		q.count(&q.dropped, "dropped", 1)
+		logger.Warnf("Сообщение в чат %d отброшено: %v", chatID, errOutgoingQueueFull)
+		return tgbotapi.Message{}, errOutgoingQueueFull
+	}
+	outbox.pending = append(outbox.pending, c)
+	// Сообщения чата отправляет одна горутина, поэтому их порядок сохраняется
+	if len(outbox.pending) == 1 {
+		q.wg.Add(1)
+		go q.drain(chatID, outbox)
+	}
+	return tgbotapi.Message{}, nil
+}
+
+// Request выполняет запрос сразу, минуя очередь
+func (q *OutgoingQueue) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	_, span := startSpan(q.ctx, "telegram.Request", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("telegram.request", telegramRequestName(c)),
+	))
+	resp, err := q.sender.Request(c)
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) {
+		span.SetAttributes(attribute.Int("telegram.error_code", apiErr.Code))
+	}
+	endSpan(span, err)
+	return resp, err
+}
+
+// Stats возвращает счётчики очереди
+func (q *OutgoingQueue) Stats() OutgoingStats {
+	return OutgoingStats{
+		Sent:    q.sent.Load(),
+		Retried: q.retried.Load(),
+		Failed:  q.failed.Load(),
+		Dropped: q.dropped.Load(),
+		Blocked: q.blockedChats.Load(),
+	}
+}
+
+// count увеличивает счётчик очереди и соответствующую метрику telegram_messages_total
+func (q *OutgoingQueue) count(counter *atomic.Int64, result string, n int64) {
+	counter.Add(n)
+	observeTelegramMessages(result, n)
+}
+
+// Stop прекращает отправку и ждёт завершения текущих запросов;
+// неотправленные сообщения отбрасываются
+func (q *OutgoingQueue) Stop(ctx context.Context) {
+	q.cancel()
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logger.Warn("Очередь сообщений Telegram не успела остановиться")
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var pending int
+	for _, outbox := range q.chats {
+		pending += len(outbox.pending)
+	}
+	if pending > 0 {
+		q.count(&q.dropped, "dropped", int64(pending))
+		logger.Warnf("При остановке не отправлено сообщений Telegram: %d", pending)
+	}
+}
+
+// drain по очереди отправляет сообщения чата, пока они не закончатся
+func (q *OutgoingQueue) drain(chatID int64, outbox *chatOutbox) {
+	defer q.wg.Done()
+	for {
+		q.mu.Lock()
+		if len(outbox.pending) == 0 || q.ctx.Err() != nil {
+			q.mu.Unlock()
+			return
+		}
+		c := outbox.pending[0]
+		q.mu.Unlock()
+
+		err := q.deliver(chatID, outbox, c)
+
+		q.mu.Lock()
+		if errors.Is(err, context.Canceled) {
+			q.mu.Unlock()
+			return
+		}
+		outbox.pending = outbox.pending[1:]
+		outbox.next = q.now().Add(q.cfg.ChatInterval)
+		var apiErr *tgbotapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden {
+			// В заблокированный чат остальные сообщения тоже не дойдут
+			q.count(&q.dropped, "dropped", int64(len(outbox.pending)))
+			outbox.pending = nil
+		}
+		// Пустая очередь остаётся в q.chats, чтобы помнить интервал до следующего сообщения
+		if len(outbox.pending) == 0 {
+			q.mu.Unlock()
+			return
+		}
+		q.mu.Unlock()
+	}
+}
+
+// pruneLocked удаляет пустые очереди чатов, которым уже можно писать; вызывается под q.mu
+func (q *OutgoingQueue) pruneLocked() {
+	now := q.now()
+	for chatID, outbox := range q.chats {
+		if len(outbox.pending) == 0 && !outbox.next.After(now) {
+			delete(q.chats, chatID)
+		}
+	}
+}
+
+// deliver отправляет одно сообщение, соблюдая ограничения и повторяя его после 429
+func (q *OutgoingQueue) deliver(chatID int64, outbox *chatOutbox, c tgbotapi.Chattable) (err error) {
+	_, span := startSpan(q.ctx, "telegram.Send", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("telegram.request", telegramRequestName(c)),
+		attribute.Int64("telegram.chat_id", chatID),
+	))
+	retries := 0
+	defer func() {
+		span.SetAttributes(attribute.Int("telegram.retries", retries))
+		endSpan(span, err)
+	}()
+
+	for attempt := 0; ; attempt++ {
+		retries = attempt
+		if !q.wait(q.ctx, q.reserve(outbox)) {
+			return context.Canceled
+		}
+		_, err := q.sender.Send(c)
+		if err == nil {
+			q.count(&q.sent, "sent", 1)
+			return nil
+		}
+		var apiErr *tgbotapi.Error
+		if !errors.As(err, &apiErr) {
+			q.count(&q.failed, "failed", 1)
+			logger.Errorf("Ошибка отправки сообщения в чат %d: %v", chatID, err)
+			return err
+		}
+		span.SetAttributes(attribute.Int("telegram.error_code", apiErr.Code))
+		switch {
+		case apiErr.Code == http.StatusTooManyRequests && attempt < q.cfg.MaxRetries:
+			retryAfter := time.Duration(apiErr.RetryAfter) * time.Second
+			q.count(&q.retried, "retried", 1)
+			logger.Warnf("Telegram ограничил отправку в чат %d, повтор через %s", chatID, retryAfter)
+			q.mu.Lock()
+			outbox.next = q.now().Add(retryAfter)
+			q.mu.Unlock()
+		case apiErr.Code == http.StatusForbidden:
+			q.count(&q.blockedChats, "blocked", 1)
+			logger.Warnf("Бот не может писать в чат %d: %s", chatID, apiErr.Message)
+			if q.blocked != nil {
+				q.blocked(chatID)
+			}
+			return err
+		default:
+			q.count(&q.failed, "failed", 1)
+			logger.Errorf("Ошибка отправки сообщения в чат %d: %v", chatID, err)
+			return err
+		}
+	}
+}
+
+// reserve занимает ближайший момент отправки с учётом ограничений чата и общего
+// лимита и возвращает, сколько до него ждать
+func (q *OutgoingQueue) reserve(outbox *chatOutbox) time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.now()
+	slot := now
+	if outbox.next.After(slot) {
+		slot = outbox.next
+	}
+	if q.nextGlobal.After(slot) {
+		slot = q.nextGlobal
+	}
+	q.nextGlobal = slot.Add(time.Second / time.Duration(q.cfg.GlobalRate))
+	return slot.Sub(now)
+}
+
+// chattableChatID возвращает чат, которому адресован запрос (0 для прочих запросов)
+func chattableChatID(c tgbotapi.Chattable) int64 {
+	switch c := c.(type) {
+	case tgbotapi.MessageConfig:
+		return c.ChatID
+	case tgbotapi.EditMessageTextConfig:
+		return c.ChatID
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		return c.ChatID
+	}
+	return 0
+}
+
+// sleepContext ждёт d и возвращает false, если контекст отменён раньше
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
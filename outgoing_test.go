@@ -0,0 +1,207 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// stubSender отвечает на Send ошибками из errs (по очереди для каждого чата), затем успехом
+type stubSender struct {
+	mu   sync.Mutex
+	errs map[int64][]error
+	sent []tgbotapi.MessageConfig
+}
+
+func (s *stubSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msg := c.(tgbotapi.MessageConfig)
+	if errs := s.errs[msg.ChatID]; len(errs) > 0 {
+		s.errs[msg.ChatID] = errs[1:]
+		return tgbotapi.Message{}, errs[0]
+	}
+	s.sent = append(s.sent, msg)
+	return tgbotapi.Message{}, nil
+}
+
+func (s *stubSender) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
+func (s *stubSender) texts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var texts []string
+	for _, msg := range s.sent {
+		texts = append(texts, msg.Text)
+	}
+	return texts
+}
+
+// newTestOutgoingQueue создаёт очередь с виртуальным временем: ожидания
+// записываются в waits и сдвигают часы
+func newTestOutgoingQueue(sender telegramSender, cfg OutgoingConfig, blocked func(int64)) (*OutgoingQueue, *[]time.Duration) {
+	q := NewOutgoingQueue(sender, cfg, blocked)
+	var mu sync.Mutex
+	now := time.Unix(0, 0)
+	var waits []time.Duration
+	q.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	q.wait = func(ctx context.Context, d time.Duration) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if d > 0 {
+			waits = append(waits, d)
+			now = now.Add(d)
+		}
+		return ctx.Err() == nil
+	}
+	return q, &waits
+}
+
+func TestOutgoingQueue_PerChatInterval(t *testing.T) {
+	sender := &stubSender{}
+	q, waits := newTestOutgoingQueue(sender, OutgoingConfig{GlobalRate: 1000, ChatInterval: time.Second}, nil)
+	for _, text := range []string{"первый", "второй", "третий"} {
+		if _, err := q.Send(tgbotapi.NewMessage(1, text)); err != nil {
+			t.Fatalf("Send error: %v", err)
+		}
+	}
+	q.wg.Wait()
+
+	if texts := sender.texts(); len(texts) != 3 || texts[0] != "первый" || texts[2] != "третий" {
+		t.Fatalf("sent = %q, want messages in order", texts)
+	}
+	if len(*waits) != 2 || (*waits)[0] != time.Second || (*waits)[1] != time.Second {
+		t.Errorf("waits = %v, want two 1s pauses between messages to one chat", *waits)
+	}
+	if stats := q.Stats(); stats.Sent != 3 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestOutgoingQueue_GlobalRate(t *testing.T) {
+	q, _ := newTestOutgoingQueue(&stubSender{}, OutgoingConfig{GlobalRate: 10}, nil)
+	var outboxes []*chatOutbox
+	for i := 0; i < 3; i++ {
+		outboxes = append(outboxes, &chatOutbox{})
+	}
+	// Разные чаты получают последовательные слоты через 1/10 секунды
+	for i, outbox := range outboxes {
+		if wait := q.reserve(outbox); wait != time.Duration(i)*100*time.Millisecond {
+			t.Errorf("reserve #%d = %v", i, wait)
+		}
+	}
+}
+
+func TestOutgoingQueue_RetryAfter(t *testing.T) {
+	tooMany := &tgbotapi.Error{Code: http.StatusTooManyRequests, Message: "Too Many Requests", ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 7}}
+	sender := &stubSender{errs: map[int64][]error{1: {tooMany}}}
+	q, waits := newTestOutgoingQueue(sender, OutgoingConfig{GlobalRate: 1000}, nil)
+	q.Send(tgbotapi.NewMessage(1, "анекдот"))
+	q.wg.Wait()
+
+	if texts := sender.texts(); len(texts) != 1 {
+		t.Fatalf("sent = %q, want the message after retry", texts)
+	}
+	if len(*waits) != 1 || (*waits)[0] != 7*time.Second {
+		t.Errorf("waits = %v, want retry_after 7s", *waits)
+	}
+	if stats := q.Stats(); stats.Retried != 1 || stats.Sent != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestOutgoingQueue_RetryLimit(t *testing.T) {
+	tooMany := &tgbotapi.Error{Code: http.StatusTooManyRequests, ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 1}}
+	sender := &stubSender{errs: map[int64][]error{1: {tooMany, tooMany, tooMany}}}
+	q, _ := newTestOutgoingQueue(sender, OutgoingConfig{MaxRetries: 2}, nil)
+	q.Send(tgbotapi.NewMessage(1, "анекдот"))
+	q.wg.Wait()
+
+	if stats := q.Stats(); stats.Retried != 2 || stats.Failed != 1 || stats.Sent != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestOutgoingQueue_Blocked(t *testing.T) {
+	forbidden := &tgbotapi.Error{Code: http.StatusForbidden, Message: "Forbidden: bot was blocked by the user"}
+	sender := &stubSender{errs: map[int64][]error{1: {forbidden}}}
+	var blocked []int64
+	q, _ := newTestOutgoingQueue(sender, OutgoingConfig{}, func(chatID int64) { blocked = append(blocked, chatID) })
+
+	q.Send(tgbotapi.NewMessage(1, "первый"))
+	q.Send(tgbotapi.NewMessage(1, "второй"))
+	q.Send(tgbotapi.NewMessage(2, "другой чат"))
+	q.wg.Wait()
+
+	if len(blocked) != 1 || blocked[0] != 1 {
+		t.Errorf("blocked = %v, want [1]", blocked)
+	}
+	if texts := sender.texts(); len(texts) != 1 || texts[0] != "другой чат" {
+		t.Errorf("sent = %q", texts)
+	}
+	if stats := q.Stats(); stats.Blocked != 1 || stats.Sent != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestOutgoingQueue_FullAndStopped(t *testing.T) {
+	dropped := telegramMessagesTotal.WithLabelValues("dropped")
+	before := testutil.ToFloat64(dropped)
+	q, _ := newTestOutgoingQueue(&stubSender{}, OutgoingConfig{ChatQueueSize: 1}, nil)
+	q.wait = func(ctx context.Context, d time.Duration) bool {
+		<-ctx.Done()
+		return false
+	}
+	if _, err := q.Send(tgbotapi.NewMessage(1, "первый")); err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+	if _, err := q.Send(tgbotapi.NewMessage(1, "второй")); !errors.Is(err, errOutgoingQueueFull) {
+		t.Errorf("Send to a full queue = %v", err)
+	}
+	q.Stop(context.Background())
+	if _, err := q.Send(tgbotapi.NewMessage(1, "третий")); !errors.Is(err, errOutgoingQueueStopped) {
+		t.Errorf("Send after Stop = %v", err)
+	}
+	if stats := q.Stats(); stats.Dropped != 3 {
+		t.Errorf("stats = %+v, want 3 dropped", stats)
+	}
+	if got := testutil.ToFloat64(dropped) - before; got != 3 {
+		t.Errorf("telegram_messages_total{result=\"dropped\"} grew by %v, want 3", got)
+	}
+}
+
+func TestProcessTelegramUpdate_BlockedChatUnsubscribed(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	bot := newTestJokeBot(t, api)
+	scheduler, err := NewSubscriptionScheduler(SubscriptionsConfig{}, nil, func(Subscription) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot.subscriptions = scheduler
+	scheduler.Subscribe(context.Background(), Subscription{ChatID: 400, At: "09:00", Timezone: "UTC"})
+
+	forbidden := &tgbotapi.Error{Code: http.StatusForbidden, Message: "Forbidden: bot was blocked by the user"}
+	q, _ := newTestOutgoingQueue(&stubSender{errs: map[int64][]error{400: {forbidden}}}, OutgoingConfig{}, bot.forgetChat)
+	bot.sender = q
+	bot.sendScheduledJoke(Subscription{ChatID: 400, At: "09:00"})
+	q.wg.Wait()
+
+	if subs := scheduler.List(400); len(subs) != 0 {
+		t.Errorf("subscriptions of a blocked chat: %v", subs)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Параметры предзагрузки по умолчанию
+const (
+	defaultPrefetchBufferSize   = 5
+	defaultPrefetchFetchTimeout = 5 * time.Second
+	defaultPrefetchRetryDelay   = 5 * time.Second
+	maxPrefetchRetryDelay       = 2 * time.Minute
+)
+
+// PrefetchConfig описывает фоновую предзагрузку анекдотов
+type PrefetchConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	BufferSize   int           `yaml:"buffer_size"`
+	FetchTimeout time.Duration `yaml:"fetch_timeout"`
+	RetryDelay   time.Duration `yaml:"retry_delay"`
+}
+
+// upstreamReporter реализуют обёртки, которые сами сообщают о запросах к настоящему
+// провайдеру (например, отдающие анекдоты из буфера). Реестр учитывает в выключателе
+// только эти запросы, а не ответы обёртки.
+type upstreamReporter interface {
+	ObserveUpstream(observe func(latency time.Duration, err error))
+	// FetchUpstream запрашивает анекдот у самого провайдера в обход обёртки
+	FetchUpstream(ctx context.Context) (Joke, error)
+}
+
+// PrefetchProvider оборачивает провайдера и держит ограниченный буфер готовых
+// анекдотов, который заполняется в фоне. Если буфер пуст, анекдот запрашивается напрямую.
+type PrefetchProvider struct {
+	inner        JokeProvider
+	buffer       chan Joke
+	fetchTimeout time.Duration
+	retryDelay   time.Duration
+	// observe получает результат каждого запроса к inner
+	observe func(latency time.Duration, err error)
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewPrefetchProvider создаёт обёртку; фоновая загрузка начинается после Start
+func NewPrefetchProvider(inner JokeProvider, cfg PrefetchConfig) *PrefetchProvider {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = defaultPrefetchBufferSize
+	}
+	if cfg.FetchTimeout <= 0 {
+		cfg.FetchTimeout = defaultPrefetchFetchTimeout
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = defaultPrefetchRetryDelay
+	}
+	return &PrefetchProvider{
+		inner:        inner,
+		buffer:       make(chan Joke, cfg.BufferSize),
+		fetchTimeout: cfg.FetchTimeout,
+		retryDelay:   cfg.RetryDelay,
+	}
+}
+
+func (p *PrefetchProvider) Name() string {
+	return p.inner.Name()
+}
+
+// FetchJoke отдаёт анекдот из буфера, а при пустом буфере обращается к провайдеру
+func (p *PrefetchProvider) FetchJoke(ctx context.Context) (Joke, error) {
+	select {
+	case joke := <-p.buffer:
+		observeCache("prefetch", true)
+		return joke, nil
+	default:
+	}
+	observeCache("prefetch", false)
+	logger.Debugf("Буфер %s пуст, запрашиваем анекдот напрямую", p.Name())
+	return p.fetchInner(ctx)
+}
+
+// ObserveUpstream задаёт получателя результатов запросов к провайдеру; вызывается до Start
+func (p *PrefetchProvider) ObserveUpstream(observe func(latency time.Duration, err error)) {
+	p.observe = observe
+}
+
+// FetchUpstream запрашивает анекдот у провайдера, не трогая буфер
+func (p *PrefetchProvider) FetchUpstream(ctx context.Context) (Joke, error) {
+	return p.fetchInner(ctx)
+}
+
+// fetchInner запрашивает анекдот у провайдера и сообщает о результате.
+// Отмена запроса не говорит о здоровье провайдера и не передаётся.
+func (p *PrefetchProvider) fetchInner(ctx context.Context) (Joke, error) {
+	start := time.Now()
+	joke, err := p.inner.FetchJoke(ctx)
+	if p.observe != nil && !errors.Is(ctx.Err(), context.Canceled) {
+		p.observe(time.Since(start), err)
+	}
+	return joke, err
+}
+
+// Buffered возвращает количество готовых анекдотов в буфере
+func (p *PrefetchProvider) Buffered() int {
+	return len(p.buffer)
+}
+
+// Start запускает фоновое заполнение буфера
+func (p *PrefetchProvider) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.wg.Add(1)
+	go p.fill(ctx)
+}
+
+// Stop останавливает фоновое заполнение и дожидается завершения горутины
+func (p *PrefetchProvider) Stop() {
+	p.mu.Lock()
+	cancel := p.cancel
+	p.cancel = nil
+	p.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	p.wg.Wait()
+}
+
+// fill поддерживает буфер заполненным, увеличивая паузу после ошибок подряд
+func (p *PrefetchProvider) fill(ctx context.Context) {
+	defer p.wg.Done()
+	delay := p.retryDelay
+	for {
+		fetchCtx, cancel := context.WithTimeout(ctx, p.fetchTimeout)
+		joke, err := p.fetchInner(fetchCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Warnf("Ошибка предзагрузки анекдота от %s: %v", p.Name(), err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			if delay *= 2; delay > maxPrefetchRetryDelay {
+				delay = maxPrefetchRetryDelay
+			}
+			continue
+		}
+		delay = p.retryDelay
+
+		select {
+		case <-ctx.Done():
+			return
+		case p.buffer <- joke:
+		}
+	}
+}
@@ -0,0 +1,119 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingProvider возвращает пронумерованные анекдоты
+type countingProvider struct {
+	calls atomic.Int32
+	err   error
+}
+
+func (p *countingProvider) Name() string { return "counting" }
+
+func (p *countingProvider) FetchJoke(ctx context.Context) (Joke, error) {
+	n := p.calls.Add(1)
+	if p.err != nil {
+		return Joke{}, p.err
+	}
+	return Joke{Text: string(rune('a' + n - 1)), Source: "counting"}, nil
+}
+
+func TestPrefetchProvider_ServesFromBuffer(t *testing.T) {
+	inner := &countingProvider{}
+	prefetcher := NewPrefetchProvider(inner, PrefetchConfig{BufferSize: 3})
+	prefetcher.Start()
+	defer prefetcher.Stop()
+
+	deadline := time.Now().Add(time.Second)
+	for prefetcher.Buffered() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("buffer not filled, got %d", prefetcher.Buffered())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	joke, err := prefetcher.FetchJoke(context.Background())
+	if err != nil {
+		t.Fatalf("FetchJoke error: %v", err)
+	}
+	if joke.Text != "a" {
+		t.Errorf("expected first buffered joke, got %q", joke.Text)
+	}
+}
+
+func TestPrefetchProvider_FallsBackToLiveFetch(t *testing.T) {
+	inner := &countingProvider{}
+	prefetcher := NewPrefetchProvider(inner, PrefetchConfig{})
+
+	// Фоновая загрузка не запущена, буфер пуст
+	if _, err := prefetcher.FetchJoke(context.Background()); err != nil {
+		t.Fatalf("FetchJoke error: %v", err)
+	}
+	if inner.calls.Load() != 1 {
+		t.Errorf("expected one live fetch, got %d", inner.calls.Load())
+	}
+
+	inner.err = errors.New("down")
+	if _, err := prefetcher.FetchJoke(context.Background()); err == nil {
+		t.Error("expected live fetch error to be returned")
+	}
+}
+
+func TestPrefetchProvider_BreakerCountsOnlyUpstreamFetches(t *testing.T) {
+	inner := &countingProvider{err: errors.New("down")}
+	registry := NewProviderRegistry(ProviderSpec{Provider: inner, Weight: 1})
+	var prefetcher *PrefetchProvider
+	registry.Wrap(func(p JokeProvider) JokeProvider {
+		prefetcher = NewPrefetchProvider(p, PrefetchConfig{BufferSize: 2})
+		return prefetcher
+	})
+	// Буфер заполнен до того, как провайдер перестал отвечать
+	prefetcher.buffer <- Joke{Text: "a", Source: "counting"}
+	prefetcher.buffer <- Joke{Text: "b", Source: "counting"}
+
+	for i := 0; i < 2; i++ {
+		if _, err := registry.FetchJoke(context.Background()); err != nil {
+			t.Fatalf("expected buffered joke, got %v", err)
+		}
+	}
+	if stats := registry.Stats()[0]; stats.Successes != 0 {
+		t.Errorf("buffer hits should not count as provider successes, got %d", stats.Successes)
+	}
+
+	// Дальше буфер пуст, и ошибки прямых запросов открывают выключатель
+	for i := 0; i < defaultFailureThreshold; i++ {
+		registry.FetchJoke(context.Background())
+	}
+	if stats := registry.Stats()[0]; stats.State != "open" || stats.Failures != defaultFailureThreshold {
+		t.Errorf("expected open breaker after %d upstream failures, got %+v", defaultFailureThreshold, stats)
+	}
+}
+
+func TestPrefetchProvider_ReportsBackgroundFetches(t *testing.T) {
+	inner := &countingProvider{err: errors.New("down")}
+	registry := NewProviderRegistry(ProviderSpec{Provider: inner, Weight: 1})
+	var prefetcher *PrefetchProvider
+	registry.Wrap(func(p JokeProvider) JokeProvider {
+		prefetcher = NewPrefetchProvider(p, PrefetchConfig{RetryDelay: time.Hour})
+		return prefetcher
+	})
+	prefetcher.Start()
+	defer prefetcher.Stop()
+
+	deadline := time.Now().Add(time.Second)
+	for registry.Stats()[0].Failures == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("background fetch failure was not recorded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
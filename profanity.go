@@ -0,0 +1,117 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Уровни фильтра ненормативной лексики
+const (
+	profanityOff    = "off"    // анекдоты отправляются как есть
+	profanityMask   = "mask"   // бранные слова заменяются звёздочками
+	profanityStrict = "strict" // анекдоты с бранью перезапрашиваются, в крайнем случае маскируются
+)
+
+// profanityAttempts — сколько раз запрашивать анекдот без брани на уровне strict
+const profanityAttempts = 3
+
+var (
+	// profanityRoots — корни русских бранных слов; совпадают с началом слова (с учётом
+	// приставок), потому что окончаний и суффиксов у них слишком много для перечисления
+	profanityRoots = []string{
+		"хуй", "хуе", "хуя", "пизд", "ебал", "ебан", "ебат", "ебну", "ебл", "ебуч", "уеб",
+		"бляд", "блят", "мудак", "мудил", "залуп", "гандон", "пидор", "пидар", "шлюх", "дроч",
+	}
+	// profanityEnglishRoots — корни английских бранных слов; совпадают только вместе
+	// с окончанием из profanityEnglishSuffixes, чтобы не ловить слова вроде shitake
+	profanityEnglishRoots = []string{
+		"fuck", "shit", "bitch", "cunt", "asshole", "motherfuck", "whore", "slut",
+	}
+	// profanityEnglishSuffixes — окончания, с которыми встречаются корни из profanityEnglishRoots
+	profanityEnglishSuffixes = []string{"", "s", "es", "ed", "er", "ers", "ing", "in", "y", "ty"}
+	// profanityWords — бранные слова, которые совпадают только целиком
+	profanityWords = map[string]bool{
+		"бля": true, "сука": true, "суки": true, "суку": true, "сукой": true,
+		"dick": true, "cock": true, "pussy": true, "bastard": true,
+	}
+	// profanityPrefixes — приставки, с которыми встречаются корни из profanityRoots
+	profanityPrefixes = []string{
+		"", "на", "за", "по", "вы", "от", "отъ", "об", "объ", "до", "под", "подъ", "при", "раз", "разъ", "рас",
+		"съ", "с", "пере", "недо", "у", "о",
+	}
+)
+
+// validProfanityLevel проверяет уровень фильтра
+func validProfanityLevel(level string) bool {
+	switch level {
+	case profanityOff, profanityMask, profanityStrict:
+		return true
+	}
+	return false
+}
+
+// isProfaneWord проверяет одно слово (в нижнем регистре)
+func isProfaneWord(word string) bool {
+	word = strings.ReplaceAll(word, "ё", "е")
+	if profanityWords[word] {
+		return true
+	}
+	for _, root := range profanityEnglishRoots {
+		if rest, ok := strings.CutPrefix(word, root); ok && slices.Contains(profanityEnglishSuffixes, rest) {
+			return true
+		}
+	}
+	for _, prefix := range profanityPrefixes {
+		rest, ok := strings.CutPrefix(word, prefix)
+		if !ok {
+			continue
+		}
+		for _, root := range profanityRoots {
+			if strings.HasPrefix(rest, root) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsProfanity сообщает, есть ли в тексте бранные слова
+func containsProfanity(text string) bool {
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), isNotLetter) {
+		if isProfaneWord(word) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskProfanity заменяет в бранных словах все буквы, кроме первой, звёздочками
+func maskProfanity(text string) string {
+	var b strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if isNotLetter(runes[i]) {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && !isNotLetter(runes[j]) {
+			j++
+		}
+		word := runes[i:j]
+		if isProfaneWord(strings.ToLower(string(word))) {
+			b.WriteRune(word[0])
+			b.WriteString(strings.Repeat("*", len(word)-1))
+		} else {
+			b.WriteString(string(word))
+		}
+		i = j
+	}
+	return b.String()
+}
+
+func isNotLetter(r rune) bool {
+	return !unicode.IsLetter(r)
+}
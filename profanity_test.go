@@ -0,0 +1,42 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import "testing"
+
+func TestContainsProfanity(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"Приходит мужик к врачу", false},
+		{"Ну ты и мудак, Вася!", true},
+		{"Он всех заебал", true},
+		{"Бля, опять понедельник", true},
+		{"Сукно и скипидар", false},
+		{"Оскорбление не считается", false},
+		{"What the FUCK is this", true},
+		{"A cocktail walks into a bar", false},
+		{"Shitake mushrooms for the assistant", false},
+		{"Cuntline of the ship", false},
+		{"Употреблять скипидар, колеблясь", false},
+		{"That fucking shitty day", true},
+		{"Motherfuckers everywhere", true},
+	}
+	for _, tt := range tests {
+		if got := containsProfanity(tt.text); got != tt.want {
+			t.Errorf("containsProfanity(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestMaskProfanity(t *testing.T) {
+	got := maskProfanity("Ну ты и мудак, Вася! Fuck.")
+	if want := "Ну ты и м****, Вася! F***."; got != want {
+		t.Errorf("maskProfanity = %q, want %q", got, want)
+	}
+	if text := "Приходит мужик к врачу"; maskProfanity(text) != text {
+		t.Errorf("clean text changed: %q", maskProfanity(text))
+	}
+}
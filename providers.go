@@ -12,18 +12,81 @@ import (
 	"time"
 )
 
+// Адреса провайдеров по умолчанию (переопределяются через base_url в config.yaml)
+const (
+	dadJokeBaseURL    = "https://icanhazdadjoke.com"
+	rzhunemoguBaseURL = "http://rzhunemogu.ru"
+	anekdotRuBaseURL  = "https://www.anekdot.ru"
+	baneksBaseURL     = "https://baneks.ru"
+	jokeAPIBaseURL    = "https://v2.jokeapi.dev"
+
+	dadJokeUserAgent = "MyJokeService (https://github.com/yourusername/joke-service)"
+)
+
+// providerInfo описывает известного провайдера: язык и способ создания
+// (client может быть nil — тогда используется общий клиент)
+type providerInfo struct {
+	russian bool
+	build   func(cfg ProviderConfig, client *http.Client) JokeProvider
+}
+
+// providerCatalog содержит провайдеров, доступных по имени из config.yaml
+var providerCatalog = map[string]providerInfo{
+	"rzhunemogu.ru": {russian: true, build: func(cfg ProviderConfig, client *http.Client) JokeProvider {
+		return RzhunemoguProvider{BaseURL: cfg.BaseURL, UserAgent: cfg.UserAgent, Client: client}
+	}},
+	"anekdot.ru": {russian: true, build: func(cfg ProviderConfig, client *http.Client) JokeProvider {
+		return AnekdotRuProvider{BaseURL: cfg.BaseURL, UserAgent: cfg.UserAgent, Client: client}
+	}},
+	"baneks.ru": {russian: true, build: func(cfg ProviderConfig, client *http.Client) JokeProvider {
+		return BaneksProvider{BaseURL: cfg.BaseURL, UserAgent: cfg.UserAgent, Client: client}
+	}},
+	"icanhazdadjoke.com": {russian: false, build: func(cfg ProviderConfig, client *http.Client) JokeProvider {
+		return DadJokeProvider{BaseURL: cfg.BaseURL, UserAgent: cfg.UserAgent, Client: client}
+	}},
+	"jokeapi.dev": {russian: false, build: func(cfg ProviderConfig, client *http.Client) JokeProvider {
+		return JokeAPIProvider{BaseURL: cfg.BaseURL, UserAgent: cfg.UserAgent, Client: client}
+	}},
+}
+
+// newProviderRequest создаёт GET-запрос к провайдеру
+func newProviderRequest(ctx context.Context, baseURL, path, userAgent string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(baseURL, "/")+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	return req, nil
+}
+
+// orDefault возвращает значение по умолчанию для пустой строки
+func orDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
 // DadJokeProvider для icanhazdadjoke.com
-type DadJokeProvider struct{}
+type DadJokeProvider struct {
+	BaseURL   string
+	UserAgent string
+	Client    *http.Client // nil — общий клиент upstreamClient
+}
+
+func (p DadJokeProvider) Name() string {
+	return "icanhazdadjoke.com"
+}
 
 func (p DadJokeProvider) FetchJoke(ctx context.Context) (Joke, error) {
-	url := "https://icanhazdadjoke.com"
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	req, err := newProviderRequest(ctx, orDefault(p.BaseURL, dadJokeBaseURL), "/", orDefault(p.UserAgent, dadJokeUserAgent))
 	if err != nil {
 		return Joke{}, err
 	}
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", "MyJokeService (https://github.com/yourusername/joke-service)")
-	client := &http.Client{}
+	client := httpClientOr(p.Client)
 	resp, err := client.Do(req)
 	if err != nil {
 		return Joke{}, err
@@ -47,16 +110,24 @@ func (p DadJokeProvider) FetchJoke(ctx context.Context) (Joke, error) {
 }
 
 // RzhunemoguProvider для rzhunemogu.ru
-type RzhunemoguProvider struct{}
+type RzhunemoguProvider struct {
+	BaseURL   string
+	UserAgent string
+	Client    *http.Client // nil — общий клиент upstreamClient
+}
+
+func (p RzhunemoguProvider) Name() string {
+	return "rzhunemogu.ru"
+}
 
 func (p RzhunemoguProvider) FetchJoke(ctx context.Context) (Joke, error) {
-	logger.Info("Fetching joke from rzhunemogu.ru")
-	url := "http://rzhunemogu.ru/RandJSON.aspx?CType=1"
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	log := logFor(ctx)
+	log.Debug("Fetching joke from rzhunemogu.ru")
+	req, err := newProviderRequest(ctx, orDefault(p.BaseURL, rzhunemoguBaseURL), "/RandJSON.aspx?CType=1", p.UserAgent)
 	if err != nil {
 		return Joke{}, fmt.Errorf("ошибка создания запроса: %v", err)
 	}
-	client := &http.Client{}
+	client := httpClientOr(p.Client)
 	resp, err := client.Do(req)
 	if err != nil {
 		return Joke{}, fmt.Errorf("ошибка выполнения запроса: %v", err)
@@ -77,7 +148,7 @@ func (p RzhunemoguProvider) FetchJoke(ctx context.Context) (Joke, error) {
 	body = bytes.TrimSpace(body)
 	body = bytes.ReplaceAll(body, []byte("\r\n"), []byte("\\n"))
 
-	logger.Infof("Response from rzhunemogu.ru (decoded): %s", string(body))
+	log.Debugf("Response from rzhunemogu.ru (decoded): %s", string(body))
 
 	var result struct {
 		Content string `json:"content"`
@@ -93,21 +164,29 @@ func (p RzhunemoguProvider) FetchJoke(ctx context.Context) (Joke, error) {
 	result.Content = strings.ReplaceAll(result.Content, "\\n", "\n")
 	result.Content = strings.TrimSpace(result.Content)
 
-	logger.Info("Successfully fetched joke from rzhunemogu.ru")
+	log.Info("Successfully fetched joke from rzhunemogu.ru")
 	return Joke{Text: result.Content, Source: "rzhunemogu.ru", IsRussian: true}, nil
 }
 
 // AnekdotRuProvider для anekdot.ru
-type AnekdotRuProvider struct{}
+type AnekdotRuProvider struct {
+	BaseURL   string
+	UserAgent string
+	Client    *http.Client // nil — общий клиент upstreamClient
+}
+
+func (p AnekdotRuProvider) Name() string {
+	return "anekdot.ru"
+}
 
 func (p AnekdotRuProvider) FetchJoke(ctx context.Context) (Joke, error) {
-	logger.Info("Fetching joke from anekdot.ru")
-	url := "https://www.anekdot.ru/rss/randomu.html"
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	log := logFor(ctx)
+	log.Debug("Fetching joke from anekdot.ru")
+	req, err := newProviderRequest(ctx, orDefault(p.BaseURL, anekdotRuBaseURL), "/rss/randomu.html", p.UserAgent)
 	if err != nil {
 		return Joke{}, fmt.Errorf("ошибка создания запроса: %v", err)
 	}
-	client := &http.Client{}
+	client := httpClientOr(p.Client)
 	resp, err := client.Do(req)
 	if err != nil {
 		return Joke{}, fmt.Errorf("ошибка выполнения запроса: %v", err)
@@ -153,60 +232,69 @@ func (p AnekdotRuProvider) FetchJoke(ctx context.Context) (Joke, error) {
 		return Joke{}, fmt.Errorf("получен пустой анекдот от anekdot.ru")
 	}
 
-	logger.Info("Successfully fetched joke from anekdot.ru")
+	log.Info("Successfully fetched joke from anekdot.ru")
 	return Joke{Text: joke, Source: "anekdot.ru", IsRussian: true}, nil
 }
 
 // BaneksProvider для baneks.ru
-type BaneksProvider struct{}
+type BaneksProvider struct {
+	BaseURL   string
+	UserAgent string
+	Client    *http.Client // nil — общий клиент upstreamClient
+}
+
+func (p BaneksProvider) Name() string {
+	return "baneks.ru"
+}
 
 func (p BaneksProvider) FetchJoke(ctx context.Context) (Joke, error) {
-	logger.Info("Fetching joke from baneks.ru")
-	url := "https://baneks.ru/random"
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	log := logFor(ctx)
+	log.Debug("Fetching joke from baneks.ru")
+	req, err := newProviderRequest(ctx, orDefault(p.BaseURL, baneksBaseURL), "/random", p.UserAgent)
 	if err != nil {
-		logger.Errorf("Ошибка создания запроса к baneks.ru: %v", err)
+		log.Errorf("Ошибка создания запроса к baneks.ru: %v", err)
 		return Joke{}, err
 	}
-	client := &http.Client{}
+	client := httpClientOr(p.Client)
 	resp, err := client.Do(req)
 	if err != nil {
-		logger.Errorf("Ошибка выполнения запроса к baneks.ru: %v", err)
+		log.Errorf("Ошибка выполнения запроса к baneks.ru: %v", err)
 		return Joke{}, err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		logger.Errorf("Ошибка чтения тела ответа от baneks.ru: %v", err)
+		log.Errorf("Ошибка чтения тела ответа от baneks.ru: %v", err)
 		return Joke{}, err
 	}
 
 	content := string(body)
-	logger.Debugf("Получен ответ от baneks.ru (первые 500 символов): %s", content[:min(500, len(content))])
+	log.Debugf("Получен ответ от baneks.ru (первые 500 символов): %s", content[:min(500, len(content))])
 
 	// Ищем meta description
 	descStart := strings.Index(content, `<meta name="description" content="`)
 	if descStart == -1 {
-		logger.Error("Не найден meta description в ответе")
+		log.Error("Не найден meta description в ответе")
 		return Joke{}, fmt.Errorf("не удалось найти анекдот")
 	}
 	descStart += len(`<meta name="description" content="`)
-	logger.Debugf("Найдена позиция начала анекдота: %d", descStart)
+	log.Debugf("Найдена позиция начала анекдота: %d", descStart)
 
 	descEnd := strings.Index(content[descStart:], `">`)
 	if descEnd == -1 {
-		logger.Error("Не найден закрывающий тег meta description")
+		log.Error("Не найден закрывающий тег meta description")
 		return Joke{}, fmt.Errorf("не удалось найти конец анекдота")
 	}
-	logger.Debugf("Найдена позиция конца анекдота: %d", descEnd)
+	log.Debugf("Найдена позиция конца анекдота: %d", descEnd)
 
 	joke := content[descStart : descStart+descEnd]
 	joke = strings.ReplaceAll(joke, "\\n", "\n")
 	joke = strings.ReplaceAll(joke, "\\\"", "\"")
 	joke = strings.TrimSpace(joke)
 
-	logger.Infof("Успешно получен анекдот от baneks.ru: %s", joke)
+	log.Info("Успешно получен анекдот от baneks.ru")
+	log.Debugf("Анекдот от baneks.ru: %s", joke)
 	return Joke{Text: joke, Source: "baneks.ru", IsRussian: true}, nil
 }
 
@@ -219,16 +307,27 @@ func min(a, b int) int {
 }
 
 // JokeAPIProvider для jokeapi.dev
-type JokeAPIProvider struct{}
+type JokeAPIProvider struct {
+	BaseURL   string
+	UserAgent string
+	Client    *http.Client // nil — общий клиент upstreamClient
+}
+
+func (p JokeAPIProvider) Name() string {
+	return "jokeapi.dev"
+}
 
 func (p JokeAPIProvider) FetchJoke(ctx context.Context) (Joke, error) {
-	url := "https://v2.jokeapi.dev/joke/Any?type=single"
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	// Заданный base_url указывает на свой экземпляр JokeAPI, ему можно передать контекст трассы
+	if p.BaseURL != "" {
+		ctx = withTracePropagation(ctx)
+	}
+	req, err := newProviderRequest(ctx, orDefault(p.BaseURL, jokeAPIBaseURL), "/joke/Any?type=single", p.UserAgent)
 	if err != nil {
 		return Joke{}, err
 	}
 	req.Header.Set("Accept", "application/json")
-	client := &http.Client{}
+	client := httpClientOr(p.Client)
 	resp, err := client.Do(req)
 	if err != nil {
 		return Joke{}, err
@@ -259,21 +358,3 @@ func (p JokeAPIProvider) FetchJoke(ctx context.Context) (Joke, error) {
 	}
 	return Joke{}, fmt.Errorf("JokeAPI: пустой анекдот")
 }
-
-// selectWeightedProvider выбирает провайдер анекдотов с учетом весов
-func selectWeightedProvider() JokeProvider {
-	totalWeight := 0
-	for _, w := range providerWeights {
-		totalWeight += w
-	}
-
-	r := rand.Intn(totalWeight)
-	curr := 0
-	for i, w := range providerWeights {
-		curr += w
-		if r < curr {
-			return jokeProviders[i]
-		}
-	}
-	return jokeProviders[0]
-}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Ограничения частоты команд бота по умолчанию
+const (
+	defaultChatRateBurst = 10
+	defaultChatRateEvery = 6 * time.Second
+	defaultUserRateBurst = 3
+	defaultUserRateEvery = 10 * time.Second
+
+	// maxRateBuckets — после этого числа корзин полностью восстановившиеся удаляются
+	maxRateBuckets = 10000
+)
+
+// TokenBucketConfig описывает корзину токенов: Burst команд подряд,
+// затем одна команда каждые Every
+type TokenBucketConfig struct {
+	Burst int           `yaml:"burst"`
+	Every time.Duration `yaml:"every"`
+}
+
+func (c TokenBucketConfig) validate(name string) error {
+	if c.Burst < 0 {
+		return fmt.Errorf("%s.burst не может быть отрицательным", name)
+	}
+	if c.Every < 0 {
+		return fmt.Errorf("%s.every не может быть отрицательным", name)
+	}
+	return nil
+}
+
+func (c TokenBucketConfig) withDefaults(burst int, every time.Duration) TokenBucketConfig {
+	if c.Burst <= 0 {
+		c.Burst = burst
+	}
+	if c.Every <= 0 {
+		c.Every = every
+	}
+	return c
+}
+
+// BotRateLimitConfig ограничивает частоту команд в чате и от одного пользователя в чате
+type BotRateLimitConfig struct {
+	Chat TokenBucketConfig `yaml:"chat"`
+	User TokenBucketConfig `yaml:"user"`
+}
+
+func (c BotRateLimitConfig) validate() error {
+	if err := c.Chat.validate("telegram.rate_limit.chat"); err != nil {
+		return err
+	}
+	return c.User.validate("telegram.rate_limit.user")
+}
+
+type tokenBucket struct {
+	tokens   float64
+	updated  time.Time
+	notified bool // о паузе уже сообщили, пока корзина пуста
+}
+
+// take забирает токен из корзины
+func (b *tokenBucket) take() {
+	b.tokens--
+	b.notified = false
+}
+
+// reject отмечает отказ и сообщает, первый ли это отказ подряд
+func (b *tokenBucket) reject() (notify bool) {
+	notify = !b.notified
+	b.notified = true
+	return notify
+}
+
+// RateLimiter — набор корзин токенов с общими параметрами, по одной на ключ
+type RateLimiter[K comparable] struct {
+	cfg TokenBucketConfig
+	now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[K]*tokenBucket
+}
+
+// NewRateLimiter создаёт ограничитель с параметрами cfg
+func NewRateLimiter[K comparable](cfg TokenBucketConfig) *RateLimiter[K] {
+	return &RateLimiter[K]{cfg: cfg, now: time.Now, buckets: make(map[K]*tokenBucket)}
+}
+
+// Allow забирает токен из корзины key. Если токенов нет, возвращает время до
+// появления следующего и признак того, что об ограничении стоит сообщить
+// (только при первом отказе подряд).
+func (l *RateLimiter[K]) Allow(key K) (ok bool, wait time.Duration, notify bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, wait := l.available(key)
+	if wait == 0 {
+		b.take()
+		return true, 0, false
+	}
+	return false, wait, b.reject()
+}
+
+// available возвращает пополненную корзину key и время до появления в ней токена
+// (0 — токен есть), ничего не забирая; вызывается под l.mu
+func (l *RateLimiter[K]) available(key K) (*tokenBucket, time.Duration) {
+	b := l.refill(key, l.now())
+	if b.tokens >= 1 {
+		return b, 0
+	}
+	return b, time.Duration((1 - b.tokens) * float64(l.cfg.Every))
+}
+
+// refill возвращает корзину key, пополненную на момент now; вызывается под l.mu
+func (l *RateLimiter[K]) refill(key K, now time.Time) *tokenBucket {
+	burst := float64(l.cfg.Burst)
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxRateBuckets {
+			l.prune(now)
+		}
+		b = &tokenBucket{tokens: burst, updated: now}
+		l.buckets[key] = b
+		return b
+	}
+	b.tokens = math.Min(burst, b.tokens+float64(now.Sub(b.updated))/float64(l.cfg.Every))
+	b.updated = now
+	return b
+}
+
+// prune удаляет полностью восстановившиеся корзины; вызывается под l.mu
+func (l *RateLimiter[K]) prune(now time.Time) {
+	full := time.Duration(l.cfg.Burst) * l.cfg.Every
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) >= full {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// chatUser — пользователь в конкретном чате
+type chatUser struct {
+	chatID, userID int64
+}
+
+// CommandLimiter ограничивает команды бота одновременно по чату и по пользователю в чате
+type CommandLimiter struct {
+	chats *RateLimiter[int64]
+	users *RateLimiter[chatUser]
+}
+
+// NewCommandLimiter создаёт ограничитель команд; нулевые параметры заменяются значениями по умолчанию
+func NewCommandLimiter(cfg BotRateLimitConfig) *CommandLimiter {
+	return &CommandLimiter{
+		chats: NewRateLimiter[int64](cfg.Chat.withDefaults(defaultChatRateBurst, defaultChatRateEvery)),
+		users: NewRateLimiter[chatUser](cfg.User.withDefaults(defaultUserRateBurst, defaultUserRateEvery)),
+	}
+}
+
+// Allow проверяет, можно ли выполнить команду пользователя userID в чате chatID.
+// Токены забираются, только если их хватает в обеих корзинах: отклонённая команда
+// не расходует ни лимит пользователя, ни лимит чата.
+func (l *CommandLimiter) Allow(chatID, userID int64) (ok bool, wait time.Duration, notify bool) {
+	// Корзины блокируются всегда в одном порядке: сначала пользователи, затем чаты
+	l.users.mu.Lock()
+	defer l.users.mu.Unlock()
+	l.chats.mu.Lock()
+	defer l.chats.mu.Unlock()
+
+	user, userWait := l.users.available(chatUser{chatID, userID})
+	chat, chatWait := l.chats.available(chatID)
+	if userWait == 0 && chatWait == 0 {
+		user.take()
+		chat.take()
+		return true, 0, false
+	}
+	if userWait > 0 {
+		notify = user.reject()
+	}
+	if chatWait > 0 {
+		notify = chat.reject() || notify
+	}
+	return false, max(userWait, chatWait), notify
+}
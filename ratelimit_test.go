@@ -0,0 +1,106 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := NewRateLimiter[int64](TokenBucketConfig{Burst: 2, Every: 10 * time.Second})
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _, _ := limiter.Allow(1); !ok {
+			t.Fatalf("command %d rejected within burst", i)
+		}
+	}
+	ok, wait, notify := limiter.Allow(1)
+	if ok || wait != 10*time.Second || !notify {
+		t.Fatalf("Allow after burst = %v, %v, %v", ok, wait, notify)
+	}
+	// О паузе сообщается один раз
+	now = now.Add(4 * time.Second)
+	if ok, wait, notify := limiter.Allow(1); ok || wait != 6*time.Second || notify {
+		t.Fatalf("repeated Allow = %v, %v, %v", ok, wait, notify)
+	}
+	// Другие ключи не затронуты
+	if ok, _, _ := limiter.Allow(2); !ok {
+		t.Fatal("other key rejected")
+	}
+
+	now = now.Add(6 * time.Second)
+	if ok, _, _ := limiter.Allow(1); !ok {
+		t.Fatal("token not refilled")
+	}
+	if _, _, notify := limiter.Allow(1); !notify {
+		t.Error("cooldown notice not repeated after a successful command")
+	}
+}
+
+func TestRateLimiter_Prune(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := NewRateLimiter[int64](TokenBucketConfig{Burst: 1, Every: time.Second})
+	limiter.now = func() time.Time { return now }
+	for key := int64(0); key < maxRateBuckets; key++ {
+		limiter.Allow(key)
+	}
+	now = now.Add(time.Second)
+	limiter.Allow(-1)
+	if n := len(limiter.buckets); n != 1 {
+		t.Errorf("buckets after prune = %d, want 1", n)
+	}
+}
+
+func TestCommandLimiter_UserAndChat(t *testing.T) {
+	limiter := NewCommandLimiter(BotRateLimitConfig{
+		Chat: TokenBucketConfig{Burst: 3, Every: time.Hour},
+		User: TokenBucketConfig{Burst: 2, Every: time.Hour},
+	})
+	allowed := 0
+	for _, user := range []int64{1, 1, 1, 2, 2} {
+		if ok, _, _ := limiter.Allow(100, user); ok {
+			allowed++
+		}
+	}
+	// Пользователь 1 ограничен двумя командами, весь чат — тремя
+	if allowed != 3 {
+		t.Errorf("allowed = %d, want 3", allowed)
+	}
+	// Лимит пользователя считается отдельно в каждом чате
+	if ok, _, _ := limiter.Allow(200, 1); !ok {
+		t.Error("user limited in another chat")
+	}
+}
+
+func TestCommandLimiter_RejectedCommandKeepsTokens(t *testing.T) {
+	limiter := NewCommandLimiter(BotRateLimitConfig{
+		Chat: TokenBucketConfig{Burst: 1, Every: time.Hour},
+		User: TokenBucketConfig{Burst: 2, Every: time.Hour},
+	})
+	if ok, _, _ := limiter.Allow(100, 1); !ok {
+		t.Fatal("first command rejected")
+	}
+	// Чат исчерпан: команда отклоняется, не забирая токен пользователя
+	if ok, _, notify := limiter.Allow(100, 1); ok || !notify {
+		t.Fatalf("Allow in an exhausted chat = %v, notify %v", ok, notify)
+	}
+	if ok, _, _ := limiter.Allow(200, 1); !ok {
+		t.Error("command in another chat rejected")
+	}
+	if ok, _, _ := limiter.Allow(100, 2); ok {
+		t.Error("chat limit not applied to another user")
+	}
+}
+
+func TestBotRateLimitConfig_Validate(t *testing.T) {
+	if err := (BotRateLimitConfig{}).validate(); err != nil {
+		t.Errorf("zero config: %v", err)
+	}
+	if err := (BotRateLimitConfig{User: TokenBucketConfig{Every: -time.Second}}).validate(); err == nil {
+		t.Error("expected error for negative interval")
+	}
+}
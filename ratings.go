@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Параметры рейтинга анекдотов
+const (
+	defaultTopCount = 10
+	maxTopCount     = 50
+
+	// ratingPriorVotes — сколько «нейтральных» голосов добавляется к оценке источника,
+	// чтобы пара случайных голосов не меняла его вес
+	ratingPriorVotes = 10
+	// ratingWeightInfluence — насколько средняя оценка меняет вес провайдера (±50%)
+	ratingWeightInfluence = 0.5
+)
+
+// Значения голоса
+const (
+	voteUp   = 1
+	voteDown = -1
+)
+
+// voteCallbackPrefix — префикс callback_data кнопок оценки: vote:<ID>:up или vote:<ID>:down
+const voteCallbackPrefix = "vote:"
+
+// errUnknownJoke возвращается при голосовании за анекдот, которого сервис не выдавал
+var errUnknownJoke = errors.New("анекдот не найден")
+
+// JokeRating — анекдот с его оценками
+type JokeRating struct {
+	ID    string `json:"id"`
+	Joke  Joke   `json:"joke"`
+	Up    int    `json:"up"`
+	Down  int    `json:"down"`
+	Score int    `json:"score"`
+}
+
+// SourceRating — суммарные оценки анекдотов одного источника
+type SourceRating struct {
+	Source       string  `json:"source"`
+	Up           int     `json:"up"`
+	Down         int     `json:"down"`
+	WeightFactor float64 `json:"weight_factor"`
+}
+
+// ratingStore — постоянное хранилище голосов
+type ratingStore interface {
+	LoadVotes(ctx context.Context) ([]storedVote, error)
+	SaveVote(ctx context.Context, vote storedVote) error
+}
+
+// storedVote — голос пользователя за анекдот
+type storedVote struct {
+	JokeID string
+	Joke   Joke
+	Voter  string
+	Value  int
+}
+
+type ratedJoke struct {
+	joke  Joke
+	votes map[string]int
+}
+
+// Ratings хранит голоса за анекдоты (по одному голосу от пользователя)
+// и считает рейтинги анекдотов и источников
+type Ratings struct {
+	store ratingStore
+
+	mu      sync.Mutex
+	jokes   map[string]*ratedJoke
+	sources map[string]*SourceRating
+}
+
+// NewRatings создаёт рейтинг и загружает голоса из store (store может быть nil)
+func NewRatings(store ratingStore) (*Ratings, error) {
+	r := &Ratings{
+		store:   store,
+		jokes:   make(map[string]*ratedJoke),
+		sources: make(map[string]*SourceRating),
+	}
+	if store == nil {
+		return r, nil
+	}
+	votes, err := store.LoadVotes(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки оценок: %w", err)
+	}
+	for _, v := range votes {
+		r.apply(v)
+	}
+	return r, nil
+}
+
+// Vote учитывает голос voter за анекдот; повторный голос заменяет предыдущий
+func (r *Ratings) Vote(ctx context.Context, id string, joke Joke, voter string, value int) (JokeRating, error) {
+	if value != voteUp && value != voteDown {
+		return JokeRating{}, fmt.Errorf("некорректный голос %d", value)
+	}
+	vote := storedVote{JokeID: id, Joke: joke, Voter: voter, Value: value}
+	if r.store != nil {
+		if err := r.store.SaveVote(ctx, vote); err != nil {
+			return JokeRating{}, err
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.apply(vote)
+	return r.ratingLocked(id), nil
+}
+
+// Lookup возвращает анекдот, за который уже голосовали
+func (r *Ratings) Lookup(id string) (Joke, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rated, ok := r.jokes[id]
+	if !ok {
+		return Joke{}, false
+	}
+	return rated.joke, true
+}
+
+// Top возвращает n анекдотов с наибольшей оценкой
+func (r *Ratings) Top(n int) []JokeRating {
+	r.mu.Lock()
+	top := make([]JokeRating, 0, len(r.jokes))
+	for id := range r.jokes {
+		if rating := r.ratingLocked(id); rating.Score > 0 {
+			top = append(top, rating)
+		}
+	}
+	r.mu.Unlock()
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Score != top[j].Score {
+			return top[i].Score > top[j].Score
+		}
+		if top[i].Up != top[j].Up {
+			return top[i].Up > top[j].Up
+		}
+		return top[i].ID < top[j].ID
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// Sources возвращает оценки источников, отсортированные по имени
+func (r *Ratings) Sources() []SourceRating {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sources := make([]SourceRating, 0, len(r.sources))
+	for _, s := range r.sources {
+		rating := *s
+		rating.WeightFactor = sourceWeightFactor(s.Up, s.Down)
+		sources = append(sources, rating)
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Source < sources[j].Source })
+	return sources
+}
+
+// WeightFactor возвращает множитель веса провайдера по средней оценке его анекдотов:
+// от 0.5 (все против) до 1.5 (все за), 1 — нет голосов
+func (r *Ratings) WeightFactor(source string) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sources[source]
+	if !ok {
+		return 1
+	}
+	return sourceWeightFactor(s.Up, s.Down)
+}
+
+func sourceWeightFactor(up, down int) float64 {
+	score := float64(up-down) / float64(up+down+ratingPriorVotes)
+	return 1 + ratingWeightInfluence*score
+}
+
+// apply учитывает голос; вызывается под r.mu либо до публикации Ratings
+func (r *Ratings) apply(v storedVote) {
+	rated, ok := r.jokes[v.JokeID]
+	if !ok {
+		rated = &ratedJoke{joke: v.Joke, votes: make(map[string]int)}
+		r.jokes[v.JokeID] = rated
+	}
+	source, ok := r.sources[rated.joke.Source]
+	if !ok {
+		source = &SourceRating{Source: rated.joke.Source}
+		r.sources[rated.joke.Source] = source
+	}
+	switch rated.votes[v.Voter] {
+	case voteUp:
+		source.Up--
+	case voteDown:
+		source.Down--
+	}
+	rated.votes[v.Voter] = v.Value
+	if v.Value == voteUp {
+		source.Up++
+	} else {
+		source.Down++
+	}
+}
+
+func (r *Ratings) ratingLocked(id string) JokeRating {
+	rated := r.jokes[id]
+	rating := JokeRating{ID: id, Joke: rated.joke}
+	for _, value := range rated.votes {
+		if value == voteUp {
+			rating.Up++
+		} else {
+			rating.Down++
+		}
+	}
+	rating.Score = rating.Up - rating.Down
+	return rating
+}
+
+// VoteByID учитывает голос за выданный анекдот: анекдот ищется в memory,
+// а если он там устарел — среди уже оценённых
+func (r *Ratings) VoteByID(ctx context.Context, memory *JokeMemory, id, voter string, value int) (JokeRating, error) {
+	joke, ok := memory.Lookup(ctx, id)
+	if !ok {
+		if joke, ok = r.Lookup(id); !ok {
+			return JokeRating{}, errUnknownJoke
+		}
+	}
+	return r.Vote(ctx, id, joke, voter, value)
+}
+
+// telegramVoterKey возвращает ключ голосующего пользователя Telegram
+func telegramVoterKey(userID int64) string {
+	return "tg-user:" + strconv.FormatInt(userID, 10)
+}
+
+// voteCallbackData возвращает callback_data кнопки оценки анекдота
+func voteCallbackData(id string, value int) string {
+	if value == voteUp {
+		return voteCallbackPrefix + id + ":up"
+	}
+	return voteCallbackPrefix + id + ":down"
+}
+
+// parseVoteCallback извлекает ID анекдота и голос из callback_data кнопки оценки
+func parseVoteCallback(data string) (string, int, bool) {
+	rest, ok := strings.CutPrefix(data, voteCallbackPrefix)
+	if !ok {
+		return "", 0, false
+	}
+	id, vote, ok := strings.Cut(rest, ":")
+	if !ok || id == "" {
+		return "", 0, false
+	}
+	value, ok := parseVote(vote)
+	return id, value, ok
+}
+
+// parseVote разбирает значение голоса: up или down
+func parseVote(value string) (int, bool) {
+	switch value {
+	case "up":
+		return voteUp, true
+	case "down":
+		return voteDown, true
+	}
+	return 0, false
+}
+
+// voteJokeHandler обрабатывает POST /jokes/{id}/vote с телом {"vote": "up"} или {"vote": "down"}
+func voteJokeHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Vote string `json:"vote"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Некорректный запрос", http.StatusBadRequest)
+		return
+	}
+	value, ok := parseVote(body.Vote)
+	if !ok {
+		http.Error(w, "vote должен быть up или down", http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	rating, err := jokeRatings.VoteByID(ctx, webJokes, mux.Vars(r)["id"], sessionClientKey(w, r), value)
+	if errors.Is(err, errUnknownJoke) {
+		http.Error(w, "Анекдот не найден", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logFor(r.Context()).Errorf("Ошибка сохранения оценки: %v", err)
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rating)
+}
+
+// getTopJokes обрабатывает GET /jokes/top?limit=N: лучшие анекдоты и оценки источников
+func getTopJokes(w http.ResponseWriter, r *http.Request) {
+	limit := defaultTopCount
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit должен быть положительным числом", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxTopCount)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Jokes   []JokeRating   `json:"jokes"`
+		Sources []SourceRating `json:"sources"`
+	}{jokeRatings.Top(limit), jokeRatings.Sources()})
+}
+
+// LoadVotes возвращает все сохранённые голоса
+func (s *Storage) LoadVotes(ctx context.Context) ([]storedVote, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT v.joke_id, j.text, j.source, j.is_russian, v.voter, v.value
+		FROM votes v JOIN rated_jokes j ON j.id = v.joke_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var votes []storedVote
+	for rows.Next() {
+		var v storedVote
+		if err := rows.Scan(&v.JokeID, &v.Joke.Text, &v.Joke.Source, &v.Joke.IsRussian, &v.Voter, &v.Value); err != nil {
+			return nil, err
+		}
+		votes = append(votes, v)
+	}
+	return votes, rows.Err()
+}
+
+// SaveVote сохраняет голос, заменяя предыдущий голос того же пользователя
+func (s *Storage) SaveVote(ctx context.Context, v storedVote) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx,
+		`INSERT OR IGNORE INTO rated_jokes (id, text, source, is_russian) VALUES (?, ?, ?, ?)`,
+		v.JokeID, v.Joke.Text, v.Joke.Source, v.Joke.IsRussian); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT OR REPLACE INTO votes (joke_id, voter, value, created_at) VALUES (?, ?, ?, ?)`,
+		v.JokeID, v.Voter, v.Value, time.Now().Unix()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
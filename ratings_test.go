@@ -0,0 +1,176 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRatings_VoteAndTop(t *testing.T) {
+	ratings, _ := NewRatings(nil)
+	ctx := context.Background()
+	good := Joke{Text: "good", Source: "anekdot.ru"}
+	bad := Joke{Text: "bad", Source: "jokeapi.dev"}
+
+	ratings.Vote(ctx, "g", good, "alice", voteUp)
+	ratings.Vote(ctx, "g", good, "bob", voteUp)
+	ratings.Vote(ctx, "b", bad, "alice", voteUp)
+	// Повторный голос заменяет предыдущий
+	rating, err := ratings.Vote(ctx, "b", bad, "alice", voteDown)
+	if err != nil || rating.Up != 0 || rating.Down != 1 || rating.Score != -1 {
+		t.Fatalf("unexpected rating after revote: %+v, %v", rating, err)
+	}
+	if _, err := ratings.Vote(ctx, "b", bad, "bob", 0); err == nil {
+		t.Error("expected error for invalid vote")
+	}
+
+	top := ratings.Top(10)
+	if len(top) != 1 || top[0].ID != "g" || top[0].Score != 2 {
+		t.Errorf("unexpected top: %+v", top)
+	}
+	if f := ratings.WeightFactor("anekdot.ru"); f <= 1 {
+		t.Errorf("expected liked source weight factor > 1, got %v", f)
+	}
+	if f := ratings.WeightFactor("jokeapi.dev"); f >= 1 {
+		t.Errorf("expected disliked source weight factor < 1, got %v", f)
+	}
+	if f := ratings.WeightFactor("baneks.ru"); f != 1 {
+		t.Errorf("expected neutral weight factor for unrated source, got %v", f)
+	}
+}
+
+func TestRatings_Persistent(t *testing.T) {
+	storage := openTestStorage(t)
+	ctx := context.Background()
+	first, _ := NewRatings(storage)
+	joke := Joke{Text: "persisted", Source: "baneks.ru", IsRussian: true}
+	first.Vote(ctx, "p", joke, "alice", voteUp)
+	first.Vote(ctx, "p", joke, "alice", voteDown)
+	first.Vote(ctx, "p", joke, "bob", voteUp)
+
+	// Новый рейтинг (например, после перезапуска) загружает голоса из хранилища
+	second, err := NewRatings(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := second.Lookup("p"); !ok || got != joke {
+		t.Fatalf("Lookup after restart = %+v, %v", got, ok)
+	}
+	sources := second.Sources()
+	if len(sources) != 1 || sources[0].Up != 1 || sources[0].Down != 1 {
+		t.Errorf("unexpected source ratings after restart: %+v", sources)
+	}
+}
+
+func TestRatings_VoteByID(t *testing.T) {
+	ratings, _ := NewRatings(nil)
+	memory := NewJokeMemory(10, time.Hour, nil)
+	ctx := context.Background()
+	if _, err := ratings.VoteByID(ctx, memory, "missing", "alice", voteUp); err != errUnknownJoke {
+		t.Errorf("expected errUnknownJoke, got %v", err)
+	}
+	id := memory.Remember(ctx, Joke{Text: "remembered"})
+	if rating, err := ratings.VoteByID(ctx, memory, id, "alice", voteUp); err != nil || rating.Score != 1 {
+		t.Errorf("unexpected rating %+v, %v", rating, err)
+	}
+	// Анекдот, за который голосовали, доступен и после того, как память о нём устарела
+	if rating, err := ratings.VoteByID(ctx, NewJokeMemory(10, time.Hour, nil), id, "bob", voteUp); err != nil || rating.Score != 2 {
+		t.Errorf("unexpected rating %+v, %v", rating, err)
+	}
+}
+
+func TestParseVoteCallback(t *testing.T) {
+	id, value, ok := parseVoteCallback(voteCallbackData("abc", voteDown))
+	if !ok || id != "abc" || value != voteDown {
+		t.Errorf("parseVoteCallback = %q, %d, %v", id, value, ok)
+	}
+	for _, data := range []string{"vote:", "vote:abc", "vote::up", "vote:abc:meh", "translate_joke:abc"} {
+		if _, _, ok := parseVoteCallback(data); ok {
+			t.Errorf("expected %q to be rejected", data)
+		}
+	}
+}
+
+func TestProviderRegistry_WeightFactor(t *testing.T) {
+	liked, disliked := okProvider("liked"), okProvider("disliked")
+	registry := NewProviderRegistry(
+		ProviderSpec{Provider: liked, Weight: 1},
+		ProviderSpec{Provider: disliked, Weight: 1},
+	)
+	registry.SetWeightFactor(func(name string) float64 {
+		if name == "liked" {
+			return 1.5
+		}
+		return 0.5
+	})
+	for i := 0; i < 2000; i++ {
+		registry.FetchJoke(context.Background())
+	}
+	// Ожидаемое соотношение 3:1
+	if liked.calls < 2*disliked.calls {
+		t.Errorf("expected liked provider to be chosen more often: %d vs %d", liked.calls, disliked.calls)
+	}
+	if stats := registry.Stats(); stats[0].EffectiveWeight != 1.5 || stats[1].EffectiveWeight != 0.5 {
+		t.Errorf("unexpected effective weights: %+v", stats)
+	}
+}
+
+func TestVoteJokeHandler(t *testing.T) {
+	savedMemory, savedRatings := webJokes, jokeRatings
+	defer func() { webJokes, jokeRatings = savedMemory, savedRatings }()
+	webJokes = NewJokeMemory(10, time.Hour, nil)
+	jokeRatings, _ = NewRatings(nil)
+	id := webJokes.Remember(context.Background(), Joke{Text: "web joke", Source: "anekdot.ru", IsRussian: true})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/jokes/top", getTopJokes).Methods("GET")
+	router.HandleFunc("/jokes/{id}/vote", voteJokeHandler).Methods("POST")
+
+	vote := func(id, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/jokes/"+id+"/vote", strings.NewReader(body)))
+		return w
+	}
+	if w := vote(id, `{"vote":"up"}`); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"score":1`) {
+		t.Errorf("unexpected vote response %d %s", w.Code, w.Body.String())
+	}
+	if w := vote(id, `{"vote":"sideways"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid vote, got %d", w.Code)
+	}
+	if w := vote("unknown", `{"vote":"up"}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown joke, got %d", w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/jokes/top", nil))
+	var top struct {
+		Jokes   []JokeRating   `json:"jokes"`
+		Sources []SourceRating `json:"sources"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &top); err != nil {
+		t.Fatalf("invalid /jokes/top response: %v", err)
+	}
+	if len(top.Jokes) != 1 || top.Jokes[0].ID != id || top.Jokes[0].Joke.Text != "web joke" || len(top.Sources) != 1 {
+		t.Errorf("unexpected top: %+v", top)
+	}
+}
+
+func TestJoke_MarshalJSON(t *testing.T) {
+	data, err := json.Marshal(Joke{Text: "joke", Source: "anekdot.ru", IsRussian: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"joke":"joke","source":"anekdot.ru","is_russian":true,"id":"` + jokeID("joke") + `"}`
+	if string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+}
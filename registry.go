@@ -0,0 +1,566 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Параметры автоматического выключателя (circuit breaker) по умолчанию
+const (
+	defaultFailureThreshold = 3
+	defaultOpenTimeout      = 30 * time.Second
+	fallbackTimeout         = time.Second
+	maxFilterMismatches     = 5
+)
+
+// circuitState описывает состояние выключателя провайдера
+type circuitState int
+
+const (
+	circuitClosed   circuitState = iota // провайдер работает в обычном режиме
+	circuitOpen                         // провайдер временно исключён из выбора
+	circuitHalfOpen                     // разрешён один пробный запрос
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+var (
+	// errNoProviders возвращается, когда не осталось ни одного доступного провайдера
+	errNoProviders = errors.New("нет доступных провайдеров анекдотов")
+	// errFilteredOut возвращается, когда провайдеры отдавали только не подходящие под фильтр анекдоты
+	errFilteredOut = errors.New("не удалось получить анекдот, подходящий под фильтр")
+)
+
+// ProviderStats — снимок статистики провайдера
+type ProviderStats struct {
+	Name                string        `json:"name"`
+	Weight              int           `json:"weight"`
+	EffectiveWeight     float64       `json:"effective_weight"`
+	State               string        `json:"state"`
+	Successes           uint64        `json:"successes"`
+	Failures            uint64        `json:"failures"`
+	ConsecutiveFailures int           `json:"consecutive_failures"`
+	LastLatency         time.Duration `json:"last_latency"`
+	LastSuccess         time.Time     `json:"last_success"`
+	LastError           string        `json:"last_error,omitempty"`
+}
+
+// ProviderSpec описывает провайдера при регистрации в реестре
+type ProviderSpec struct {
+	Provider JokeProvider
+	Weight   int
+	Timeout  time.Duration // ограничение на один запрос к провайдеру, 0 — без ограничения
+	Russian  bool          // провайдер отдаёт русские анекдоты
+}
+
+// registeredProvider хранит провайдера и его состояние здоровья
+type registeredProvider struct {
+	provider JokeProvider
+	weight   int
+	timeout  time.Duration
+	russian  bool
+	// selfReporting — обёртка провайдера сама сообщает о запросах к нему (upstreamReporter)
+	selfReporting bool
+
+	mu                  sync.Mutex
+	state               circuitState
+	openedAt            time.Time
+	probing             bool
+	successes           uint64
+	failures            uint64
+	consecutiveFailures int
+	lastLatency         time.Duration
+	lastSuccess         time.Time
+	lastError           string
+}
+
+// ProviderRegistry выбирает провайдеров с учётом весов и их состояния,
+// переключаясь на следующего кандидата при ошибке
+type ProviderRegistry struct {
+	entries          []*registeredProvider
+	failureThreshold int
+	openTimeout      time.Duration
+	now              func() time.Time
+	fallback         JokeProvider
+	// weightFactor корректирует вес провайдера (например, по оценкам пользователей)
+	weightFactor func(name string) float64
+}
+
+// NewProviderRegistry создаёт реестр из описаний провайдеров
+func NewProviderRegistry(specs ...ProviderSpec) *ProviderRegistry {
+	r := &ProviderRegistry{
+		failureThreshold: defaultFailureThreshold,
+		openTimeout:      defaultOpenTimeout,
+		now:              time.Now,
+	}
+	for _, s := range specs {
+		r.entries = append(r.entries, &registeredProvider{
+			provider: s.Provider,
+			weight:   s.Weight,
+			timeout:  s.Timeout,
+			russian:  s.Russian,
+		})
+	}
+	return r
+}
+
+// newProviderRegistryFromConfig создаёт реестр из включённых провайдеров конфигурации;
+// провайдеры ходят в сеть через client (nil — общий клиент)
+func newProviderRegistryFromConfig(configs []ProviderConfig, client *http.Client) (*ProviderRegistry, error) {
+	if err := validateProviderConfigs(configs); err != nil {
+		return nil, err
+	}
+	specs := make([]ProviderSpec, 0, len(configs))
+	for _, cfg := range configs {
+		if !cfg.IsEnabled() {
+			continue
+		}
+		info := providerCatalog[cfg.Name]
+		specs = append(specs, ProviderSpec{
+			Provider: info.build(cfg, client),
+			Weight:   cfg.Weight,
+			Timeout:  cfg.Timeout,
+			Russian:  info.russian,
+		})
+	}
+	return NewProviderRegistry(specs...), nil
+}
+
+// mustNewProviderRegistry — вариант newProviderRegistryFromConfig для встроенных настроек
+func mustNewProviderRegistry(configs []ProviderConfig) *ProviderRegistry {
+	r, err := newProviderRegistryFromConfig(configs, nil)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (r *ProviderRegistry) Name() string {
+	return "registry"
+}
+
+// SetWeightFactor задаёт множитель весов провайдеров; вызывается до начала обработки запросов
+func (r *ProviderRegistry) SetWeightFactor(factor func(name string) float64) {
+	r.weightFactor = factor
+}
+
+// SetFallback задаёт провайдера, к которому реестр обращается, когда все
+// основные провайдеры недоступны (например, локальный архив)
+func (r *ProviderRegistry) SetFallback(p JokeProvider) {
+	r.fallback = p
+}
+
+// FetchJoke запрашивает анекдот у взвешенно выбранного провайдера и при ошибке
+// пробует следующих кандидатов, пока не истечёт контекст
+func (r *ProviderRegistry) FetchJoke(ctx context.Context) (Joke, error) {
+	return r.FetchFilteredJoke(ctx, JokeFilter{})
+}
+
+// FetchFilteredJoke работает как FetchJoke, выбирая только провайдеров,
+// подходящих под фильтр, и отбрасывая не подходящие по длине анекдоты
+func (r *ProviderRegistry) FetchFilteredJoke(ctx context.Context, filter JokeFilter) (Joke, error) {
+	if !r.Matches(filter) {
+		return Joke{}, errNoMatchingProviders
+	}
+	joke, err := r.fetchUpstream(ctx, filter, nil, nil)
+	if err == nil || r.fallback == nil || errors.Is(ctx.Err(), context.Canceled) {
+		return joke, err
+	}
+	// Бюджет запроса мог быть исчерпан основными провайдерами
+	fallbackCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), fallbackTimeout)
+	defer cancel()
+	fallbackCtx, span := startSpan(fallbackCtx, "provider.fallback", trace.WithAttributes(
+		attribute.String("joke.provider", r.fallback.Name()),
+	))
+	joke, fallbackErr := fetchWithFilter(fallbackCtx, r.fallback, filter)
+	endSpan(span, fallbackErr)
+	if fallbackErr != nil {
+		logFor(ctx).Errorf("Резервный провайдер %s недоступен: %v", r.fallback.Name(), fallbackErr)
+		return Joke{}, err
+	}
+	logFor(ctx).Warnf("Анекдот получен от резервного провайдера %s: %v", r.fallback.Name(), err)
+	return joke, nil
+}
+
+// Matches сообщает, есть ли в реестре провайдеры, подходящие под фильтр
+// (без учёта их текущего состояния)
+func (r *ProviderRegistry) Matches(filter JokeFilter) bool {
+	for _, e := range r.entries {
+		if e.weight > 0 && filter.MatchesProvider(e.provider.Name(), e.russian) {
+			return true
+		}
+	}
+	return false
+}
+
+// openProviders возвращает имена подходящих под фильтр провайдеров с открытым выключателем
+func (r *ProviderRegistry) openProviders(filter JokeFilter) []string {
+	var names []string
+	for _, e := range r.entries {
+		if !filter.MatchesProvider(e.provider.Name(), e.russian) {
+			continue
+		}
+		e.mu.Lock()
+		if e.state == circuitOpen {
+			names = append(names, e.provider.Name())
+		}
+		e.mu.Unlock()
+	}
+	return names
+}
+
+// fetchUpstream перебирает основных провайдеров, пока не получит анекдот.
+// Если задан report, ему передаётся каждая ошибка провайдера; если заданы claims,
+// выбор по возможности избегает провайдеров, занятых параллельными запросами.
+func (r *ProviderRegistry) fetchUpstream(ctx context.Context, filter JokeFilter, report func(provider string, err error), claims *providerClaims) (result Joke, err error) {
+	ctx, span := startSpan(ctx, "providers.select", trace.WithAttributes(
+		attribute.String("filter.lang", filter.Lang),
+		attribute.StringSlice("filter.sources", filter.Sources),
+		attribute.Int("filter.max_len", filter.MaxLen),
+	))
+	attempts := 0
+	defer func() {
+		span.SetAttributes(attribute.Int("providers.attempts", attempts))
+		if err == nil {
+			span.SetAttributes(attribute.String("joke.provider", result.Source))
+		}
+		endSpan(span, err)
+	}()
+
+	tried := make(map[*registeredProvider]bool)
+	mismatches := 0
+	var lastErr error
+	for ctx.Err() == nil {
+		var entry *registeredProvider
+		if claims != nil {
+			entry = claims.pick(r, tried, filter)
+		} else {
+			entry = r.pick(tried, filter)
+		}
+		if entry == nil {
+			break
+		}
+		tried[entry] = true
+		attempts++
+
+		name := entry.provider.Name()
+		logFor(ctx).Infof("Выбран провайдер: %s", name)
+		start := r.now()
+		joke, err := entry.fetch(ctx)
+		latency := r.now().Sub(start)
+		if err == nil {
+			r.recordFetch(entry, latency, nil)
+			if filter.Matches(joke) {
+				return joke, nil
+			}
+			// Провайдер исправен, просто анекдот не подошёл — его можно спросить ещё раз
+			lastErr = errFilteredOut
+			if mismatches++; mismatches >= maxFilterMismatches {
+				break
+			}
+			delete(tried, entry)
+			continue
+		}
+		// Отмена запроса клиентом не говорит о здоровье провайдера
+		if errors.Is(ctx.Err(), context.Canceled) {
+			entry.release()
+		} else {
+			r.recordFetch(entry, latency, err)
+		}
+		logFor(ctx).Errorf("Ошибка получения анекдота от провайдера %s: %v", name, err)
+		if report != nil {
+			report(name, err)
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		if err := ctx.Err(); err != nil {
+			return Joke{}, err
+		}
+		return Joke{}, errNoProviders
+	}
+	return Joke{}, fmt.Errorf("все попытки получить анекдот завершились ошибкой: %w", lastErr)
+}
+
+// Wrap оборачивает каждого зарегистрированного провайдера (кэш, архив и т.п.).
+// Вызывается при инициализации, до начала обработки запросов.
+func (r *ProviderRegistry) Wrap(wrap func(JokeProvider) JokeProvider) {
+	for _, e := range r.entries {
+		e.provider = wrap(e.provider)
+		reporter, ok := e.provider.(upstreamReporter)
+		e.selfReporting = ok
+		if ok {
+			reporter.ObserveUpstream(func(latency time.Duration, err error) {
+				if err == nil {
+					r.recordSuccess(e, latency)
+				} else {
+					r.recordFailure(e, latency, err)
+				}
+			})
+		}
+	}
+}
+
+// Stats возвращает снимок статистики всех провайдеров
+func (r *ProviderRegistry) Stats() []ProviderStats {
+	stats := make([]ProviderStats, 0, len(r.entries))
+	for _, e := range r.entries {
+		e.mu.Lock()
+		stats = append(stats, ProviderStats{
+			Name:                e.provider.Name(),
+			Weight:              e.weight,
+			EffectiveWeight:     r.effectiveWeight(e),
+			State:               e.state.String(),
+			Successes:           e.successes,
+			Failures:            e.failures,
+			ConsecutiveFailures: e.consecutiveFailures,
+			LastLatency:         e.lastLatency,
+			LastSuccess:         e.lastSuccess,
+			LastError:           e.lastError,
+		})
+		e.mu.Unlock()
+	}
+	return stats
+}
+
+// pick выбирает доступного провайдера с учётом весов, пропуская уже опробованных.
+// Провайдер в полуоткрытом состоянии пропускает только один пробный запрос.
+func (r *ProviderRegistry) pick(exclude map[*registeredProvider]bool, filter JokeFilter) *registeredProvider {
+	if exclude == nil {
+		exclude = make(map[*registeredProvider]bool)
+	}
+	for {
+		entry := r.choose(exclude, filter)
+		if entry == nil || entry.acquire() {
+			return entry
+		}
+		// Пробный запрос уже занят другим обращением
+		exclude[entry] = true
+	}
+}
+
+// providerClaims распределяет провайдеров между параллельными запросами одного раунда,
+// чтобы каждый запрос по возможности обращался к своему провайдеру
+type providerClaims struct {
+	mu      sync.Mutex
+	claimed map[*registeredProvider]bool
+}
+
+func newProviderClaims() *providerClaims {
+	return &providerClaims{claimed: make(map[*registeredProvider]bool)}
+}
+
+// pick выбирает провайдера, не занятого другими запросами раунда. Повтор допускается,
+// только когда свободных подходящих провайдеров не осталось.
+func (c *providerClaims) pick(r *ProviderRegistry, tried map[*registeredProvider]bool, filter JokeFilter) *registeredProvider {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	exclude := make(map[*registeredProvider]bool, len(tried)+len(c.claimed))
+	for e := range tried {
+		exclude[e] = true
+	}
+	for e := range c.claimed {
+		exclude[e] = true
+	}
+	entry := r.pick(exclude, filter)
+	if entry == nil {
+		entry = r.pick(tried, filter)
+	}
+	if entry != nil {
+		c.claimed[entry] = true
+	}
+	return entry
+}
+
+// choose выполняет взвешенный случайный выбор среди доступных провайдеров
+func (r *ProviderRegistry) choose(exclude map[*registeredProvider]bool, filter JokeFilter) *registeredProvider {
+	now := r.now()
+	candidates := make([]*registeredProvider, 0, len(r.entries))
+	weights := make([]float64, 0, len(r.entries))
+	totalWeight := 0.0
+	for _, e := range r.entries {
+		if exclude[e] || e.weight <= 0 || !filter.MatchesProvider(e.provider.Name(), e.russian) || !r.available(e, now) {
+			continue
+		}
+		w := r.effectiveWeight(e)
+		candidates = append(candidates, e)
+		weights = append(weights, w)
+		totalWeight += w
+	}
+	if totalWeight <= 0 {
+		return nil
+	}
+
+	n := rand.Float64() * totalWeight
+	curr := 0.0
+	for i, e := range candidates {
+		curr += weights[i]
+		if n < curr {
+			return e
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+// effectiveWeight возвращает вес провайдера с учётом множителя
+func (r *ProviderRegistry) effectiveWeight(e *registeredProvider) float64 {
+	w := float64(e.weight)
+	if r.weightFactor != nil && w > 0 {
+		w *= r.weightFactor(e.provider.Name())
+	}
+	return w
+}
+
+// fetch запрашивает анекдот с учётом таймаута провайдера
+func (e *registeredProvider) fetch(ctx context.Context) (Joke, error) {
+	return e.fetchVia(ctx, e.provider.FetchJoke)
+}
+
+// fetchUpstream запрашивает анекдот у самого провайдера: обёртки с буфером
+// (upstreamReporter) обходятся, чтобы не расходовать готовые анекдоты
+func (e *registeredProvider) fetchUpstream(ctx context.Context) (Joke, error) {
+	if reporter, ok := e.provider.(upstreamReporter); ok {
+		return e.fetchVia(ctx, reporter.FetchUpstream)
+	}
+	return e.fetch(ctx)
+}
+
+// fetchVia вызывает fetch с таймаутом провайдера и в отдельном спане
+func (e *registeredProvider) fetchVia(ctx context.Context, fetch func(ctx context.Context) (Joke, error)) (Joke, error) {
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+	e.mu.Lock()
+	state := e.state
+	e.mu.Unlock()
+	ctx, span := startSpan(ctx, "provider.FetchJoke", trace.WithAttributes(
+		attribute.String("joke.provider", e.provider.Name()),
+		attribute.String("provider.circuit", state.String()),
+	))
+	joke, err := fetch(ctx)
+	endSpan(span, err)
+	return joke, err
+}
+
+// acquire резервирует пробный запрос для полуоткрытого выключателя
+func (e *registeredProvider) acquire() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.state != circuitHalfOpen {
+		return true
+	}
+	if e.probing {
+		return false
+	}
+	e.probing = true
+	return true
+}
+
+// fetchWithFilter запрашивает анекдот у провайдера с учётом фильтра
+func fetchWithFilter(ctx context.Context, p JokeProvider, filter JokeFilter) (Joke, error) {
+	if fp, ok := p.(FilteredProvider); ok {
+		return fp.FetchFilteredJoke(ctx, filter)
+	}
+	joke, err := p.FetchJoke(ctx)
+	if err != nil {
+		return Joke{}, err
+	}
+	if !filter.Matches(joke) {
+		return Joke{}, errFilteredOut
+	}
+	return joke, nil
+}
+
+// release освобождает пробный запрос без изменения состояния
+func (e *registeredProvider) release() {
+	e.mu.Lock()
+	e.probing = false
+	e.mu.Unlock()
+}
+
+// available сообщает, можно ли сейчас обращаться к провайдеру,
+// переводя открытый выключатель в полуоткрытый по истечении таймаута
+func (r *ProviderRegistry) available(e *registeredProvider, now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch e.state {
+	case circuitOpen:
+		if now.Sub(e.openedAt) < r.openTimeout {
+			return false
+		}
+		e.state = circuitHalfOpen
+		e.probing = false
+		logger.Infof("Провайдер %s переведён в полуоткрытое состояние", e.provider.Name())
+		return true
+	case circuitHalfOpen:
+		return !e.probing
+	default:
+		return true
+	}
+}
+
+// recordFetch учитывает ответ провайдера в статистике и выключателе. Если обёртка
+// сама сообщает о запросах к провайдеру, ответ (например, из буфера) не учитывается,
+// а только освобождается пробный запрос.
+func (r *ProviderRegistry) recordFetch(e *registeredProvider, latency time.Duration, err error) {
+	switch {
+	case e.selfReporting:
+		e.release()
+	case err == nil:
+		r.recordSuccess(e, latency)
+	default:
+		r.recordFailure(e, latency, err)
+	}
+}
+
+func (r *ProviderRegistry) recordSuccess(e *registeredProvider, latency time.Duration) {
+	observeProviderFetch(e.provider.Name(), latency, nil)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.state != circuitClosed {
+		logger.Infof("Провайдер %s снова доступен", e.provider.Name())
+	}
+	e.state = circuitClosed
+	e.probing = false
+	e.successes++
+	e.consecutiveFailures = 0
+	e.lastLatency = latency
+	e.lastSuccess = r.now()
+}
+
+func (r *ProviderRegistry) recordFailure(e *registeredProvider, latency time.Duration, err error) {
+	observeProviderFetch(e.provider.Name(), latency, err)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures++
+	e.consecutiveFailures++
+	e.lastLatency = latency
+	e.lastError = err.Error()
+	if e.state == circuitHalfOpen || e.consecutiveFailures >= r.failureThreshold {
+		if e.state != circuitOpen {
+			logger.Warnf("Провайдер %s временно отключён после %d ошибок подряд", e.provider.Name(), e.consecutiveFailures)
+		}
+		e.state = circuitOpen
+		e.openedAt = r.now()
+	}
+	e.probing = false
+}
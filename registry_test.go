@@ -0,0 +1,89 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// stubProvider — провайдер с заранее заданным поведением
+type stubProvider struct {
+	name  string
+	calls int
+	fetch func() (Joke, error)
+}
+
+func (p *stubProvider) Name() string { return p.name }
+
+func (p *stubProvider) FetchJoke(ctx context.Context) (Joke, error) {
+	p.calls++
+	return p.fetch()
+}
+
+func failingProvider(name string) *stubProvider {
+	return &stubProvider{name: name, fetch: func() (Joke, error) {
+		return Joke{}, errors.New("upstream down")
+	}}
+}
+
+func okProvider(name string) *stubProvider {
+	return &stubProvider{name: name, fetch: func() (Joke, error) {
+		return Joke{Text: "joke from " + name, Source: name}, nil
+	}}
+}
+
+func TestProviderRegistry_FailsOver(t *testing.T) {
+	bad := failingProvider("bad")
+	good := okProvider("good")
+	// Плохой провайдер имеет подавляющий вес, но запрос всё равно должен завершиться успешно
+	registry := NewProviderRegistry(ProviderSpec{Provider: bad, Weight: 1000}, ProviderSpec{Provider: good, Weight: 1})
+
+	for i := 0; i < 5; i++ {
+		joke, err := registry.FetchJoke(context.Background())
+		if err != nil {
+			t.Fatalf("FetchJoke error: %v", err)
+		}
+		if joke.Source != "good" {
+			t.Fatalf("expected joke from good provider, got %q", joke.Source)
+		}
+	}
+}
+
+func TestProviderRegistry_OpensCircuit(t *testing.T) {
+	bad := failingProvider("bad")
+	registry := NewProviderRegistry(ProviderSpec{Provider: bad, Weight: 1})
+	now := time.Now()
+	registry.now = func() time.Time { return now }
+
+	for i := 0; i < 10; i++ {
+		registry.FetchJoke(context.Background())
+	}
+	if bad.calls != defaultFailureThreshold {
+		t.Fatalf("expected %d calls to failing provider, got %d", defaultFailureThreshold, bad.calls)
+	}
+	if state := registry.Stats()[0].State; state != "open" {
+		t.Fatalf("expected open circuit, got %s", state)
+	}
+
+	// После таймаута провайдер получает один пробный запрос
+	now = now.Add(defaultOpenTimeout)
+	bad.fetch = func() (Joke, error) { return Joke{Text: "back", Source: "bad"}, nil }
+	joke, err := registry.FetchJoke(context.Background())
+	if err != nil || joke.Source != "bad" {
+		t.Fatalf("expected probe to reach recovered provider, got %q, %v", joke.Source, err)
+	}
+	if state := registry.Stats()[0].State; state != "closed" {
+		t.Fatalf("expected closed circuit after successful probe, got %s", state)
+	}
+}
+
+func TestProviderRegistry_AllProvidersDown(t *testing.T) {
+	registry := NewProviderRegistry(ProviderSpec{Provider: failingProvider("a"), Weight: 1}, ProviderSpec{Provider: failingProvider("b"), Weight: 1})
+	if _, err := registry.FetchJoke(context.Background()); err == nil {
+		t.Fatal("expected error when all providers fail")
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// StorageConfig описывает встроенное хранилище SQLite
+type StorageConfig struct {
+	// Path — путь к файлу базы; пустое значение отключает хранилище
+	Path string `yaml:"path"`
+}
+
+// Enabled сообщает, настроено ли хранилище
+func (c StorageConfig) Enabled() bool {
+	return c.Path != ""
+}
+
+// storageMigrations — шаги схемы хранилища по порядку. Номер последнего применённого
+// шага хранится в PRAGMA user_version, поэтому каждый шаг выполняется один раз;
+// новые шаги добавляются только в конец списка.
+var storageMigrations = []func(tx *sql.Tx) error{
+	execStatements(
+		`CREATE TABLE IF NOT EXISTS jokes (
+			hash       TEXT PRIMARY KEY,
+			text       TEXT NOT NULL,
+			source     TEXT NOT NULL,
+			is_russian INTEGER NOT NULL,
+			first_seen TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS jokes_is_russian ON jokes (is_russian)`,
+	),
+	execStatements(`CREATE TABLE IF NOT EXISTS translations (
+		key         TEXT PRIMARY KEY,
+		translation TEXT NOT NULL,
+		created_at  INTEGER NOT NULL
+	)`),
+	execStatements(
+		`CREATE TABLE IF NOT EXISTS bot_jokes (
+			id         TEXT PRIMARY KEY,
+			text       TEXT NOT NULL,
+			created_at INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS bot_jokes_created_at ON bot_jokes (created_at)`,
+	),
+	addColumn("bot_jokes", "source", "TEXT NOT NULL DEFAULT ''"),
+	addColumn("bot_jokes", "is_russian", "INTEGER NOT NULL DEFAULT 0"),
+	execStatements(`CREATE TABLE IF NOT EXISTS subscriptions (
+		chat_id        INTEGER NOT NULL,
+		at             TEXT NOT NULL,
+		lang           TEXT NOT NULL,
+		interval_hours INTEGER NOT NULL,
+		timezone       TEXT NOT NULL,
+		created_at     INTEGER NOT NULL,
+		PRIMARY KEY (chat_id, at)
+	)`),
+	execStatements(
+		`CREATE TABLE IF NOT EXISTS rated_jokes (
+			id         TEXT PRIMARY KEY,
+			text       TEXT NOT NULL,
+			source     TEXT NOT NULL,
+			is_russian INTEGER NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS votes (
+			joke_id    TEXT NOT NULL,
+			voter      TEXT NOT NULL,
+			value      INTEGER NOT NULL,
+			created_at INTEGER NOT NULL,
+			PRIMARY KEY (joke_id, voter)
+		)`,
+	),
+	execStatements(`CREATE TABLE IF NOT EXISTS chat_settings (
+		chat_id    INTEGER PRIMARY KEY,
+		settings   TEXT NOT NULL,
+		updated_at INTEGER NOT NULL
+	)`),
+	execStatements(`CREATE INDEX IF NOT EXISTS translations_created_at ON translations (created_at)`),
+}
+
+// execStatements возвращает шаг миграции, выполняющий stmts
+func execStatements(stmts ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, stmt := range stmts {
+			if _, err := tx.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// addColumn возвращает шаг миграции, добавляющий столбец. В базах, созданных до
+// появления user_version, столбец может уже быть — тогда шаг ничего не делает.
+func addColumn(table, column, definition string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		var exists bool
+		err := tx.QueryRow(`SELECT COUNT(*) > 0 FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&exists)
+		if err != nil || exists {
+			return err
+		}
+		_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+		return err
+	}
+}
+
+// migrateStorage применяет шаги storageMigrations, которых ещё нет в базе
+func migrateStorage(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	if version > len(storageMigrations) {
+		return fmt.Errorf("версия схемы хранилища %d новее поддерживаемой %d", version, len(storageMigrations))
+	}
+	for ; version < len(storageMigrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if err := storageMigrations[version](tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("шаг %d: %w", version+1, err)
+		}
+		// PRAGMA не поддерживает параметры запроса
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Storage — встроенная база SQLite
+type Storage struct {
+	db *sql.DB
+}
+
+// OpenStorage открывает (или создаёт) базу и применяет миграции
+func OpenStorage(path string) (*Storage, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("ошибка создания каталога хранилища: %w", err)
+		}
+	}
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия хранилища: %w", err)
+	}
+	// SQLite не поддерживает параллельную запись, одно соединение исключает SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+	if err := migrateStorage(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ошибка миграции хранилища: %w", err)
+	}
+	return &Storage{db: db}, nil
+}
+
+// Close закрывает базу
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
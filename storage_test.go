@@ -0,0 +1,81 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// userVersion возвращает PRAGMA user_version хранилища
+func userVersion(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	return version
+}
+
+func TestOpenStorage_Migrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jokes.db")
+	for i := 0; i < 2; i++ {
+		storage, err := OpenStorage(path)
+		if err != nil {
+			t.Fatalf("OpenStorage #%d error: %v", i+1, err)
+		}
+		if got := userVersion(t, storage.db); got != len(storageMigrations) {
+			t.Errorf("user_version = %d, want %d", got, len(storageMigrations))
+		}
+		storage.Close()
+	}
+}
+
+func TestOpenStorage_UnversionedDatabase(t *testing.T) {
+	// База, созданная до user_version: таблицы и столбцы уже есть, версия — 0
+	path := filepath.Join(t.TempDir(), "jokes.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE bot_jokes (id TEXT PRIMARY KEY, text TEXT NOT NULL, created_at INTEGER NOT NULL)`,
+		`ALTER TABLE bot_jokes ADD COLUMN source TEXT NOT NULL DEFAULT ''`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	storage, err := OpenStorage(path)
+	if err != nil {
+		t.Fatalf("OpenStorage error: %v", err)
+	}
+	defer storage.Close()
+	var columns int
+	if err := storage.db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('bot_jokes')`).Scan(&columns); err != nil {
+		t.Fatal(err)
+	}
+	if columns != 5 {
+		t.Errorf("bot_jokes columns = %d, want 5", columns)
+	}
+}
+
+func TestOpenStorage_NewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jokes.db")
+	storage, err := OpenStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.db.Exec(`PRAGMA user_version = 1000`); err != nil {
+		t.Fatal(err)
+	}
+	storage.Close()
+	if storage, err := OpenStorage(path); err == nil {
+		storage.Close()
+		t.Error("OpenStorage accepted a schema newer than supported")
+	}
+}
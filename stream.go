@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Параметры потока анекдотов (Server-Sent Events)
+const (
+	defaultStreamInterval = 30 * time.Second
+	minStreamInterval     = 5 * time.Second
+	maxStreamInterval     = time.Hour
+	streamKeepAlive       = 15 * time.Second
+	streamFetchTimeout    = 5 * time.Second
+)
+
+// jokeChannel — общий поток анекдотов для подписчиков с одинаковыми параметрами:
+// один запрос к провайдерам на интервал, сколько бы экранов ни было подключено
+type jokeChannel struct {
+	key         string
+	interval    time.Duration
+	filter      JokeFilter
+	subscribers map[chan Joke]struct{}
+	last        *Joke
+	cancel      context.CancelFunc
+}
+
+// JokeStreamHub раздаёт анекдоты подписчикам потоков
+type JokeStreamHub struct {
+	fetch func(ctx context.Context, filter JokeFilter) (Joke, error)
+
+	mu       sync.Mutex
+	channels map[string]*jokeChannel
+	closed   bool
+}
+
+// NewJokeStreamHub создаёт хаб, получающий анекдоты через fetch
+func NewJokeStreamHub(fetch func(ctx context.Context, filter JokeFilter) (Joke, error)) *JokeStreamHub {
+	return &JokeStreamHub{fetch: fetch, channels: make(map[string]*jokeChannel)}
+}
+
+// streamKey возвращает ключ канала для интервала и фильтра
+func streamKey(interval time.Duration, filter JokeFilter) string {
+	return fmt.Sprintf("%s|%s|%s|%d", interval, filter.Lang, strings.Join(filter.Sources, ","), filter.MaxLen)
+}
+
+// Subscribe подписывает на поток анекдотов. Новый подписчик сразу получает
+// последний разосланный анекдот. Канал закрывается при остановке хаба.
+func (h *JokeStreamHub) Subscribe(interval time.Duration, filter JokeFilter) (<-chan Joke, func()) {
+	sub := make(chan Joke, 1)
+	key := streamKey(interval, filter)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(sub)
+		return sub, func() {}
+	}
+	ch, ok := h.channels[key]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		ch = &jokeChannel{
+			key:         key,
+			interval:    interval,
+			filter:      filter,
+			subscribers: make(map[chan Joke]struct{}),
+			cancel:      cancel,
+		}
+		h.channels[key] = ch
+		go h.run(ctx, ch)
+	} else if ch.last != nil {
+		sub <- *ch.last
+	}
+	ch.subscribers[sub] = struct{}{}
+
+	var once sync.Once
+	return sub, func() { once.Do(func() { h.unsubscribe(ch, sub) }) }
+}
+
+func (h *JokeStreamHub) unsubscribe(ch *jokeChannel, sub chan Joke) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := ch.subscribers[sub]; !ok {
+		return
+	}
+	delete(ch.subscribers, sub)
+	close(sub)
+	// Последний подписчик ушёл — останавливаем загрузку
+	if len(ch.subscribers) == 0 {
+		ch.cancel()
+		delete(h.channels, ch.key)
+	}
+}
+
+// Close останавливает все потоки и закрывает каналы подписчиков
+func (h *JokeStreamHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for key, ch := range h.channels {
+		ch.cancel()
+		// Подписчики удаляются, чтобы их отложенный unsubscribe не закрыл канал повторно
+		for sub := range ch.subscribers {
+			close(sub)
+			delete(ch.subscribers, sub)
+		}
+		delete(h.channels, key)
+	}
+}
+
+// run получает анекдот сразу и затем раз в интервал, рассылая его подписчикам
+func (h *JokeStreamHub) run(ctx context.Context, ch *jokeChannel) {
+	ticker := time.NewTicker(ch.interval)
+	defer ticker.Stop()
+	for {
+		fetchCtx, cancel := context.WithTimeout(ctx, streamFetchTimeout)
+		joke, err := h.fetch(fetchCtx, ch.filter)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logFor(ctx).Errorf("Ошибка получения анекдота для потока: %v", err)
+		} else {
+			h.broadcast(ch, joke)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *JokeStreamHub) broadcast(ch *jokeChannel, joke Joke) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.channels[ch.key] != ch {
+		return
+	}
+	ch.last = &joke
+	for sub := range ch.subscribers {
+		// Медленный клиент пропускает устаревший анекдот и получает свежий
+		select {
+		case <-sub:
+		default:
+		}
+		sub <- joke
+	}
+}
+
+// streamJokes обрабатывает GET /jokes/stream?interval=30s, поддерживая фильтры /random-joke
+func streamJokes(w http.ResponseWriter, r *http.Request) {
+	interval := defaultStreamInterval
+	if raw := r.URL.Query().Get("interval"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < minStreamInterval || d > maxStreamInterval {
+			http.Error(w, fmt.Sprintf("interval должен быть от %s до %s", minStreamInterval, maxStreamInterval), http.StatusBadRequest)
+			return
+		}
+		interval = d
+	}
+	filter, err := parseJokeFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !providerRegistry.Matches(filter) {
+		http.Error(w, "Нет источников, подходящих под фильтр", http.StatusNotFound)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		logFor(r.Context()).Errorf("Поток анекдотов не поддерживается: %v", err)
+		return
+	}
+
+	jokes, unsubscribe := jokeHub.Subscribe(interval, filter)
+	defer unsubscribe()
+	logFor(r.Context()).Infof("Клиент подключился к потоку анекдотов (интервал %s)", interval)
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	id := 0
+	for {
+		select {
+		case <-r.Context().Done():
+			logFor(r.Context()).Info("Клиент отключился от потока анекдотов")
+			return
+		case joke, ok := <-jokes:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(joke)
+			if err != nil {
+				logFor(r.Context()).Errorf("Ошибка сериализации анекдота в JSON: %v", err)
+				continue
+			}
+			id++
+			fmt.Fprintf(w, "id: %d\nevent: joke\ndata: %s\n\n", id, data)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,101 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestJokeStreamHub_SharesFetches(t *testing.T) {
+	var fetches atomic.Int32
+	hub := NewJokeStreamHub(func(ctx context.Context, filter JokeFilter) (Joke, error) {
+		fetches.Add(1)
+		return Joke{Text: "shared"}, nil
+	})
+	defer hub.Close()
+
+	first, unsubscribeFirst := hub.Subscribe(time.Hour, JokeFilter{})
+	if joke := <-first; joke.Text != "shared" {
+		t.Fatalf("unexpected joke %q", joke.Text)
+	}
+	// Второй экран получает уже загруженный анекдот без нового запроса
+	second, unsubscribeSecond := hub.Subscribe(time.Hour, JokeFilter{})
+	if joke := <-second; joke.Text != "shared" {
+		t.Fatalf("unexpected joke %q", joke.Text)
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("expected one fetch for two subscribers, got %d", n)
+	}
+
+	unsubscribeFirst()
+	unsubscribeSecond()
+	hub.mu.Lock()
+	channels := len(hub.channels)
+	hub.mu.Unlock()
+	if channels != 0 {
+		t.Errorf("expected channel to stop after last subscriber left, got %d", channels)
+	}
+}
+
+func TestJokeStreamHub_CloseBeforeUnsubscribe(t *testing.T) {
+	hub := NewJokeStreamHub(func(ctx context.Context, filter JokeFilter) (Joke, error) {
+		return Joke{Text: "shared"}, nil
+	})
+	sub, unsubscribe := hub.Subscribe(time.Hour, JokeFilter{})
+
+	// При остановке сервера Close срабатывает раньше отложенного unsubscribe обработчика
+	hub.Close()
+	for range sub {
+	}
+	unsubscribe()
+}
+
+func TestStreamJokesHandler(t *testing.T) {
+	savedRegistry, savedHub := providerRegistry, jokeHub
+	defer func() { providerRegistry, jokeHub = savedRegistry, savedHub }()
+	providerRegistry = NewProviderRegistry(ProviderSpec{Provider: &countingProvider{}, Weight: 1})
+	jokeHub = NewJokeStreamHub(providerRegistry.FetchFilteredJoke)
+	defer jokeHub.Close()
+
+	server := httptest.NewServer(loggingMiddleware(http.HandlerFunc(streamJokes)))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/jokes/stream?interval=5s")
+	if err != nil {
+		t.Fatalf("GET error: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected Content-Type %q", ct)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	var event []string
+	for len(event) < 3 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read error: %v", err)
+		}
+		event = append(event, strings.TrimSpace(line))
+	}
+	if event[1] != "event: joke" || !strings.HasPrefix(event[2], `data: {"joke":"a"`) {
+		t.Errorf("unexpected event: %q", event)
+	}
+
+	resp, err = http.Get(server.URL + "/jokes/stream?interval=1s")
+	if err != nil {
+		t.Fatalf("GET error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for too short interval, got %d", resp.StatusCode)
+	}
+}
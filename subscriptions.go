@@ -0,0 +1,455 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // часовые пояса подписок не зависят от tzdata в образе
+)
+
+// Параметры подписок по умолчанию
+const (
+	defaultSubscriptionTimezone    = "Europe/Moscow"
+	defaultMaxSubscriptionsPerChat = 5
+	defaultSubscriptionWorkers     = 4
+	subscriptionTick               = 15 * time.Second
+)
+
+// subscriptionUsage — подсказка по команде /subscribe
+const subscriptionUsage = "Использование: /subscribe ЧЧ:ММ [ru|en] [Nh] [часовой пояс]\n\n" +
+	"Например: /subscribe 09:00 ru — русский анекдот каждый день в 09:00,\n" +
+	"/subscribe 10:00 6h Asia/Yekaterinburg — анекдот каждые 6 часов начиная с 10:00 по Екатеринбургу."
+
+var (
+	subscriptionTimePattern     = regexp.MustCompile(`^([01]?\d|2[0-3]):([0-5]\d)$`)
+	subscriptionIntervalPattern = regexp.MustCompile(`^(\d{1,2})[hч]$`)
+
+	// errTooManySubscriptions возвращается, когда у чата уже максимум подписок
+	errTooManySubscriptions = errors.New("слишком много подписок")
+)
+
+// SubscriptionsConfig описывает рассылку анекдотов по расписанию
+type SubscriptionsConfig struct {
+	// Timezone — часовой пояс подписок, для которых пользователь его не указал
+	Timezone   string `yaml:"timezone"`
+	MaxPerChat int    `yaml:"max_per_chat"`
+	// Workers — сколько подписок обрабатывается одновременно; отправку сообщений
+	// дополнительно выравнивает очередь исходящих сообщений
+	Workers int `yaml:"workers"`
+}
+
+func (c SubscriptionsConfig) validate() error {
+	if c.Timezone != "" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			return fmt.Errorf("telegram.subscriptions.timezone: неизвестный часовой пояс %q", c.Timezone)
+		}
+	}
+	if c.MaxPerChat < 0 {
+		return fmt.Errorf("telegram.subscriptions.max_per_chat не может быть отрицательным")
+	}
+	if c.Workers < 0 {
+		return fmt.Errorf("telegram.subscriptions.workers не может быть отрицательным")
+	}
+	return nil
+}
+
+// Subscription — подписка чата на анекдоты по расписанию
+type Subscription struct {
+	ChatID int64
+	// At — время первой отправки за сутки в формате ЧЧ:ММ
+	At string
+	// Lang — язык анекдотов: ru, en или пусто (любой)
+	Lang string
+	// IntervalHours — период отправки в часах, 0 — раз в сутки
+	IntervalHours int
+	Timezone      string
+}
+
+// parseSubscriptionTime приводит время вида 9:00 или 09:00 к формату ЧЧ:ММ
+func parseSubscriptionTime(value string) (string, bool) {
+	m := subscriptionTimePattern.FindStringSubmatch(value)
+	if m == nil {
+		return "", false
+	}
+	hour, _ := strconv.Atoi(m[1])
+	return fmt.Sprintf("%02d:%s", hour, m[2]), true
+}
+
+// parseSubscription разбирает аргументы команды /subscribe
+func parseSubscription(chatID int64, args, defaultTimezone string) (Subscription, error) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return Subscription{}, errors.New(subscriptionUsage)
+	}
+	at, ok := parseSubscriptionTime(fields[0])
+	if !ok {
+		return Subscription{}, fmt.Errorf("Не понимаю время %q, укажите его как ЧЧ:ММ, например 09:00", fields[0])
+	}
+	sub := Subscription{ChatID: chatID, At: at, Timezone: defaultTimezone}
+	for _, field := range fields[1:] {
+		lower := strings.ToLower(field)
+		switch {
+		case lower == langRussian || lower == langEnglish:
+			sub.Lang = lower
+		case subscriptionIntervalPattern.MatchString(lower):
+			n, _ := strconv.Atoi(subscriptionIntervalPattern.FindStringSubmatch(lower)[1])
+			// Период должен делить сутки, иначе отправки после полуночи сбивались бы с шага
+			if n < 1 || n > 24 || 24%n != 0 {
+				return Subscription{}, fmt.Errorf("Период должен делить сутки: 1h, 2h, 3h, 4h, 6h, 8h, 12h или 24h")
+			}
+			if n < 24 {
+				sub.IntervalHours = n
+			}
+		default:
+			if _, err := time.LoadLocation(field); err != nil || field == "Local" {
+				return Subscription{}, fmt.Errorf("Не понимаю %q. %s", field, subscriptionUsage)
+			}
+			sub.Timezone = field
+		}
+	}
+	return sub, nil
+}
+
+// location возвращает часовой пояс подписки
+func (s Subscription) location() *time.Location {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Next возвращает ближайшее время отправки строго после after
+func (s Subscription) Next(after time.Time) time.Time {
+	loc := s.location()
+	var hour, minute int
+	fmt.Sscanf(s.At, "%d:%d", &hour, &minute)
+	local := after.In(loc)
+	anchor := time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, loc)
+	if s.IntervalHours == 0 {
+		if !anchor.After(after) {
+			anchor = time.Date(local.Year(), local.Month(), local.Day()+1, hour, minute, 0, 0, loc)
+		}
+		return anchor
+	}
+	// Отправки идут каждые IntervalHours часов, начиная с At
+	interval := time.Duration(s.IntervalHours) * time.Hour
+	for anchor.After(after) {
+		anchor = anchor.Add(-interval)
+	}
+	for !anchor.After(after) {
+		anchor = anchor.Add(interval)
+	}
+	return anchor
+}
+
+// Describe возвращает описание подписки для пользователя
+func (s Subscription) Describe() string {
+	var b strings.Builder
+	switch s.Lang {
+	case langRussian:
+		b.WriteString("русский анекдот ")
+	case langEnglish:
+		b.WriteString("английский анекдот ")
+	default:
+		b.WriteString("анекдот ")
+	}
+	if s.IntervalHours == 0 {
+		fmt.Fprintf(&b, "каждый день в %s", s.At)
+	} else {
+		fmt.Fprintf(&b, "каждые %d ч начиная с %s", s.IntervalHours, s.At)
+	}
+	fmt.Fprintf(&b, " (%s)", s.Timezone)
+	return b.String()
+}
+
+// subscriptionStore — постоянное хранилище подписок
+type subscriptionStore interface {
+	ListSubscriptions(ctx context.Context) ([]Subscription, error)
+	SaveSubscription(ctx context.Context, sub Subscription) error
+	DeleteSubscription(ctx context.Context, chatID int64, at string) error
+}
+
+type subscriptionKey struct {
+	chatID int64
+	at     string
+}
+
+type scheduledSubscription struct {
+	sub  Subscription
+	next time.Time
+}
+
+// SubscriptionScheduler хранит подписки и отправляет анекдоты по расписанию
+type SubscriptionScheduler struct {
+	store      subscriptionStore
+	deliver    func(sub Subscription)
+	timezone   string
+	maxPerChat int
+	tick       time.Duration
+	now        func() time.Time
+	// workers ограничивает число одновременно обрабатываемых подписок
+	workers chan struct{}
+
+	mu      sync.Mutex
+	entries map[subscriptionKey]*scheduledSubscription
+	// pending — новые подписки, которые ещё сохраняются в store
+	pending map[subscriptionKey]bool
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewSubscriptionScheduler создаёт планировщик и загружает подписки из store
+// (store может быть nil — тогда подписки не переживают перезапуск)
+func NewSubscriptionScheduler(cfg SubscriptionsConfig, store subscriptionStore, deliver func(sub Subscription)) (*SubscriptionScheduler, error) {
+	s := &SubscriptionScheduler{
+		store:      store,
+		deliver:    deliver,
+		timezone:   orDefault(cfg.Timezone, defaultSubscriptionTimezone),
+		maxPerChat: cfg.MaxPerChat,
+		tick:       subscriptionTick,
+		now:        time.Now,
+		entries:    make(map[subscriptionKey]*scheduledSubscription),
+		pending:    make(map[subscriptionKey]bool),
+	}
+	if s.maxPerChat <= 0 {
+		s.maxPerChat = defaultMaxSubscriptionsPerChat
+	}
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = defaultSubscriptionWorkers
+	}
+	s.workers = make(chan struct{}, workers)
+	if store == nil {
+		return s, nil
+	}
+	subs, err := store.ListSubscriptions(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки подписок: %w", err)
+	}
+	now := s.now()
+	for _, sub := range subs {
+		s.entries[subscriptionKey{sub.ChatID, sub.At}] = &scheduledSubscription{sub: sub, next: sub.Next(now)}
+	}
+	return s, nil
+}
+
+// newSubscriptionSchedulerFromConfig создаёт планировщик, хранящий подписки в storage (если задано),
+// и отправляющий анекдоты через Telegram-бота
+func newSubscriptionSchedulerFromConfig(cfg SubscriptionsConfig, storage *Storage) (*SubscriptionScheduler, error) {
+	var store subscriptionStore
+	if storage != nil {
+		store = storage
+	} else {
+		logger.Warn("storage.path не задан: подписки на анекдоты не сохранятся после перезапуска")
+	}
+	return NewSubscriptionScheduler(cfg, store, func(sub Subscription) {
+		if bot := jokeBot.Load(); bot != nil {
+			bot.sendScheduledJoke(sub)
+		}
+	})
+}
+
+// Timezone возвращает часовой пояс подписок по умолчанию
+func (s *SubscriptionScheduler) Timezone() string {
+	return s.timezone
+}
+
+// Subscribe добавляет подписку или заменяет подписку чата на то же время.
+// Новая подписка резервируется до сохранения, чтобы параллельные /subscribe
+// не превысили max_per_chat.
+func (s *SubscriptionScheduler) Subscribe(ctx context.Context, sub Subscription) error {
+	key := subscriptionKey{sub.ChatID, sub.At}
+	s.mu.Lock()
+	_, exists := s.entries[key]
+	reserved := false
+	if !exists && !s.pending[key] {
+		if s.countLocked(sub.ChatID) >= s.maxPerChat {
+			s.mu.Unlock()
+			return errTooManySubscriptions
+		}
+		s.pending[key] = true
+		reserved = true
+	}
+	s.mu.Unlock()
+
+	if s.store != nil {
+		if err := s.store.SaveSubscription(ctx, sub); err != nil {
+			if reserved {
+				s.mu.Lock()
+				delete(s.pending, key)
+				s.mu.Unlock()
+			}
+			return err
+		}
+	}
+	s.mu.Lock()
+	if reserved {
+		delete(s.pending, key)
+	}
+	s.entries[key] = &scheduledSubscription{sub: sub, next: sub.Next(s.now())}
+	s.mu.Unlock()
+	return nil
+}
+
+// Unsubscribe удаляет подписку чата на время at (или все подписки чата, если at пусто)
+// и возвращает число удалённых подписок
+func (s *SubscriptionScheduler) Unsubscribe(ctx context.Context, chatID int64, at string) (int, error) {
+	removed := 0
+	for _, sub := range s.List(chatID) {
+		if at != "" && sub.At != at {
+			continue
+		}
+		if s.store != nil {
+			if err := s.store.DeleteSubscription(ctx, chatID, sub.At); err != nil {
+				return removed, err
+			}
+		}
+		s.mu.Lock()
+		delete(s.entries, subscriptionKey{chatID, sub.At})
+		s.mu.Unlock()
+		removed++
+	}
+	return removed, nil
+}
+
+// List возвращает подписки чата, отсортированные по времени
+func (s *SubscriptionScheduler) List(chatID int64) []Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var subs []Subscription
+	for key, entry := range s.entries {
+		if key.chatID == chatID {
+			subs = append(subs, entry.sub)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].At < subs[j].At })
+	return subs
+}
+
+// countLocked считает подписки чата вместе с ещё не сохранёнными
+func (s *SubscriptionScheduler) countLocked(chatID int64) int {
+	n := 0
+	for key := range s.entries {
+		if key.chatID == chatID {
+			n++
+		}
+	}
+	for key := range s.pending {
+		if _, ok := s.entries[key]; key.chatID == chatID && !ok {
+			n++
+		}
+	}
+	return n
+}
+
+// Start запускает фоновую рассылку
+func (s *SubscriptionScheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.wg.Add(1)
+	go s.run(ctx)
+}
+
+// Stop останавливает рассылку и дожидается отправки начатых анекдотов
+func (s *SubscriptionScheduler) Stop() {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.cancel = nil
+	s.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	s.wg.Wait()
+}
+
+func (s *SubscriptionScheduler) run(ctx context.Context) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.deliverDue(ctx)
+		}
+	}
+}
+
+// deliverDue отправляет анекдоты подпискам, время которых наступило. Одновременно
+// обрабатывается не больше telegram.subscriptions.workers подписок, остальные ждут
+// свободного обработчика (или остановки планировщика).
+func (s *SubscriptionScheduler) deliverDue(ctx context.Context) {
+	now := s.now()
+	var due []Subscription
+	s.mu.Lock()
+	for _, entry := range s.entries {
+		if !entry.next.After(now) {
+			due = append(due, entry.sub)
+			entry.next = entry.sub.Next(now)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, sub := range due {
+		select {
+		case s.workers <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		s.wg.Add(1)
+		go func(sub Subscription) {
+			defer func() {
+				<-s.workers
+				s.wg.Done()
+			}()
+			s.deliver(sub)
+		}(sub)
+	}
+}
+
+// ListSubscriptions возвращает все сохранённые подписки
+func (s *Storage) ListSubscriptions(ctx context.Context) ([]Subscription, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT chat_id, at, lang, interval_hours, timezone FROM subscriptions`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var subs []Subscription
+	for rows.Next() {
+		var sub Subscription
+		if err := rows.Scan(&sub.ChatID, &sub.At, &sub.Lang, &sub.IntervalHours, &sub.Timezone); err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// SaveSubscription сохраняет подписку, заменяя подписку чата на то же время
+func (s *Storage) SaveSubscription(ctx context.Context, sub Subscription) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO subscriptions (chat_id, at, lang, interval_hours, timezone, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		sub.ChatID, sub.At, sub.Lang, sub.IntervalHours, sub.Timezone, time.Now().Unix())
+	return err
+}
+
+// DeleteSubscription удаляет подписку чата на время at
+func (s *Storage) DeleteSubscription(ctx context.Context, chatID int64, at string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM subscriptions WHERE chat_id = ? AND at = ?`, chatID, at)
+	return err
+}
@@ -0,0 +1,295 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseSubscription(t *testing.T) {
+	sub, err := parseSubscription(1, "9:30 EN 6h Asia/Yekaterinburg", "Europe/Moscow")
+	if err != nil {
+		t.Fatalf("parseSubscription: %v", err)
+	}
+	want := Subscription{ChatID: 1, At: "09:30", Lang: langEnglish, IntervalHours: 6, Timezone: "Asia/Yekaterinburg"}
+	if sub != want {
+		t.Errorf("got %+v, want %+v", sub, want)
+	}
+
+	sub, err = parseSubscription(1, "08:00 24h", "Europe/Moscow")
+	if err != nil || sub.IntervalHours != 0 || sub.Timezone != "Europe/Moscow" {
+		t.Errorf("expected daily subscription in default timezone, got %+v, %v", sub, err)
+	}
+
+	for _, args := range []string{"", "25:00", "09:00 fr", "09:00 0h", "09:00 48h", "09:00 7h", "09:00 5h", "09:00 Local"} {
+		if _, err := parseSubscription(1, args, "UTC"); err == nil {
+			t.Errorf("expected error for %q", args)
+		}
+	}
+}
+
+func TestSubscription_Next(t *testing.T) {
+	moscow, _ := time.LoadLocation("Europe/Moscow")
+	now := time.Date(2024, 3, 10, 10, 0, 0, 0, moscow)
+
+	daily := Subscription{At: "09:00", Timezone: "Europe/Moscow"}
+	if got, want := daily.Next(now), time.Date(2024, 3, 11, 9, 0, 0, 0, moscow); !got.Equal(want) {
+		t.Errorf("daily Next = %v, want %v", got, want)
+	}
+	later := Subscription{At: "18:15", Timezone: "Europe/Moscow"}
+	if got, want := later.Next(now), time.Date(2024, 3, 10, 18, 15, 0, 0, moscow); !got.Equal(want) {
+		t.Errorf("same day Next = %v, want %v", got, want)
+	}
+	every := Subscription{At: "09:00", IntervalHours: 4, Timezone: "Europe/Moscow"}
+	if got, want := every.Next(now), time.Date(2024, 3, 10, 13, 0, 0, 0, moscow); !got.Equal(want) {
+		t.Errorf("interval Next = %v, want %v", got, want)
+	}
+	// Шаг, делящий сутки, сохраняется и после полуночи
+	eight := Subscription{At: "10:00", IntervalHours: 8, Timezone: "Europe/Moscow"}
+	night := time.Date(2024, 3, 10, 20, 0, 0, 0, moscow)
+	if got, want := eight.Next(night), time.Date(2024, 3, 11, 2, 0, 0, 0, moscow); !got.Equal(want) {
+		t.Errorf("overnight interval Next = %v, want %v", got, want)
+	}
+	// Время подписки считается в её часовом поясе: 10:00 по Москве — это 07:00 UTC
+	utc := Subscription{At: "09:00", Timezone: "UTC"}
+	if got, want := utc.Next(now), time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("UTC Next = %v, want %v", got, want)
+	}
+}
+
+func TestSubscriptionScheduler_SubscribeAndLimit(t *testing.T) {
+	scheduler, err := NewSubscriptionScheduler(SubscriptionsConfig{MaxPerChat: 2}, nil, func(Subscription) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, at := range []string{"09:00", "18:00", "09:00"} {
+		if err := scheduler.Subscribe(ctx, Subscription{ChatID: 1, At: at, Timezone: "UTC"}); err != nil {
+			t.Fatalf("Subscribe(%s): %v", at, err)
+		}
+	}
+	if err := scheduler.Subscribe(ctx, Subscription{ChatID: 1, At: "12:00", Timezone: "UTC"}); err != errTooManySubscriptions {
+		t.Errorf("expected errTooManySubscriptions, got %v", err)
+	}
+	if subs := scheduler.List(1); len(subs) != 2 || subs[0].At != "09:00" || subs[1].At != "18:00" {
+		t.Errorf("unexpected subscriptions: %+v", subs)
+	}
+
+	if removed, _ := scheduler.Unsubscribe(ctx, 1, "09:00"); removed != 1 {
+		t.Errorf("expected one subscription removed, got %d", removed)
+	}
+	if removed, _ := scheduler.Unsubscribe(ctx, 1, ""); removed != 1 || len(scheduler.List(1)) != 0 {
+		t.Errorf("expected all subscriptions removed, got %d", removed)
+	}
+}
+
+// slowSubscriptionStore задерживает сохранение, пока не закроют release
+type slowSubscriptionStore struct {
+	release chan struct{}
+	fail    bool
+}
+
+func (s *slowSubscriptionStore) ListSubscriptions(ctx context.Context) ([]Subscription, error) {
+	return nil, nil
+}
+
+func (s *slowSubscriptionStore) SaveSubscription(ctx context.Context, sub Subscription) error {
+	<-s.release
+	if s.fail {
+		return errors.New("disk full")
+	}
+	return nil
+}
+
+func (s *slowSubscriptionStore) DeleteSubscription(ctx context.Context, chatID int64, at string) error {
+	return nil
+}
+
+func TestSubscriptionScheduler_ConcurrentSubscribeRespectsLimit(t *testing.T) {
+	store := &slowSubscriptionStore{release: make(chan struct{})}
+	scheduler, err := NewSubscriptionScheduler(SubscriptionsConfig{MaxPerChat: 2}, store, func(Subscription) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	times := []string{"08:00", "09:00", "10:00", "11:00", "12:00"}
+	errs := make(chan error, len(times))
+	for _, at := range times {
+		go func(at string) {
+			errs <- scheduler.Subscribe(ctx, Subscription{ChatID: 1, At: at, Timezone: "UTC"})
+		}(at)
+	}
+	// Лишние подписки отклоняются ещё до сохранения
+	for i := 0; i < len(times)-2; i++ {
+		if err := <-errs; err != errTooManySubscriptions {
+			t.Errorf("expected errTooManySubscriptions, got %v", err)
+		}
+	}
+	close(store.release)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("Subscribe: %v", err)
+		}
+	}
+	if subs := scheduler.List(1); len(subs) != 2 {
+		t.Errorf("expected 2 subscriptions, got %+v", subs)
+	}
+
+	// Несохранённая подписка освобождает место
+	failing := &slowSubscriptionStore{release: make(chan struct{}), fail: true}
+	close(failing.release)
+	scheduler, _ = NewSubscriptionScheduler(SubscriptionsConfig{MaxPerChat: 1}, failing, func(Subscription) {})
+	if err := scheduler.Subscribe(ctx, Subscription{ChatID: 1, At: "08:00", Timezone: "UTC"}); err == nil {
+		t.Fatal("expected save error")
+	}
+	failing.fail = false
+	if err := scheduler.Subscribe(ctx, Subscription{ChatID: 1, At: "09:00", Timezone: "UTC"}); err != nil {
+		t.Errorf("expected reservation to be released after failed save, got %v", err)
+	}
+}
+
+func TestSubscriptionScheduler_Persistent(t *testing.T) {
+	storage := openTestStorage(t)
+	ctx := context.Background()
+	first, err := NewSubscriptionScheduler(SubscriptionsConfig{}, storage, func(Subscription) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := Subscription{ChatID: 42, At: "07:30", Lang: langRussian, Timezone: "Europe/Moscow"}
+	if err := first.Subscribe(ctx, sub); err != nil {
+		t.Fatal(err)
+	}
+
+	// Новый планировщик (например, после перезапуска) загружает подписки из хранилища
+	second, err := NewSubscriptionScheduler(SubscriptionsConfig{}, storage, func(Subscription) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subs := second.List(42); len(subs) != 1 || subs[0] != sub {
+		t.Fatalf("unexpected subscriptions after restart: %+v", subs)
+	}
+	second.Unsubscribe(ctx, 42, "")
+	if subs, _ := storage.ListSubscriptions(ctx); len(subs) != 0 {
+		t.Errorf("expected subscription to be deleted from storage, got %+v", subs)
+	}
+}
+
+func TestSubscriptionScheduler_Delivers(t *testing.T) {
+	var mu sync.Mutex
+	var delivered []Subscription
+	scheduler, _ := NewSubscriptionScheduler(SubscriptionsConfig{}, nil, func(sub Subscription) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, sub)
+	})
+	now := time.Date(2024, 3, 10, 8, 59, 0, 0, time.UTC)
+	scheduler.now = func() time.Time { return now }
+	scheduler.Subscribe(context.Background(), Subscription{ChatID: 1, At: "09:00", Timezone: "UTC"})
+
+	scheduler.deliverDue(context.Background())
+	now = now.Add(time.Minute)
+	scheduler.deliverDue(context.Background())
+	scheduler.deliverDue(context.Background())
+	scheduler.wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(delivered) != 1 || delivered[0].ChatID != 1 {
+		t.Errorf("expected exactly one delivery at 09:00, got %+v", delivered)
+	}
+}
+
+func TestSubscriptionScheduler_BoundedWorkers(t *testing.T) {
+	var mu sync.Mutex
+	var running, maxRunning, delivered int
+	scheduler, _ := NewSubscriptionScheduler(SubscriptionsConfig{Workers: 2}, nil, func(Subscription) {
+		mu.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		delivered++
+		mu.Unlock()
+	})
+	now := time.Date(2024, 3, 10, 8, 59, 0, 0, time.UTC)
+	scheduler.now = func() time.Time { return now }
+	for chatID := int64(1); chatID <= 6; chatID++ {
+		scheduler.Subscribe(context.Background(), Subscription{ChatID: chatID, At: "09:00", Timezone: "UTC"})
+	}
+
+	now = now.Add(time.Minute)
+	scheduler.deliverDue(context.Background())
+	scheduler.wg.Wait()
+
+	if delivered != 6 || maxRunning != 2 {
+		t.Errorf("delivered %d with up to %d at once, want 6 with up to 2", delivered, maxRunning)
+	}
+}
+
+func TestSubscriptionScheduler_StartStop(t *testing.T) {
+	delivered := make(chan Subscription, 1)
+	scheduler, _ := NewSubscriptionScheduler(SubscriptionsConfig{}, nil, func(sub Subscription) { delivered <- sub })
+	scheduler.tick = 10 * time.Millisecond
+	scheduler.Subscribe(context.Background(), Subscription{ChatID: 1, At: "09:00", Timezone: "UTC"})
+	scheduler.mu.Lock()
+	scheduler.entries[subscriptionKey{1, "09:00"}].next = time.Now()
+	scheduler.mu.Unlock()
+
+	scheduler.Start()
+	select {
+	case <-delivered:
+	case <-time.After(2 * time.Second):
+		t.Fatal("subscription was not delivered")
+	}
+	scheduler.Stop()
+}
+
+func TestProcessTelegramUpdate_Subscriptions(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	bot := newTestJokeBot(t, api)
+	bot.subscriptions, _ = NewSubscriptionScheduler(SubscriptionsConfig{Timezone: "UTC"}, nil, bot.sendScheduledJoke)
+
+	update := commandUpdate(300, "subscribe")
+	update.Message.Text = "/subscribe 9:00 ru"
+	bot.processTelegramUpdate(update)
+	bot.processTelegramUpdate(commandUpdate(300, "subscriptions"))
+	bot.processTelegramUpdate(commandUpdate(300, "unsubscribe"))
+
+	texts := sentTexts(api)
+	if len(texts) != 3 {
+		t.Fatalf("expected 3 replies, got %q", texts)
+	}
+	if !strings.HasPrefix(texts[0], "Подписка оформлена: русский анекдот каждый день в 09:00 (UTC)") {
+		t.Errorf("unexpected /subscribe reply: %q", texts[0])
+	}
+	if !strings.Contains(texts[1], "09:00") {
+		t.Errorf("unexpected /subscriptions reply: %q", texts[1])
+	}
+	if texts[2] != "Подписки отменены: 1" {
+		t.Errorf("unexpected /unsubscribe reply: %q", texts[2])
+	}
+}
+
+func TestJokeBot_SendScheduledJoke(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	bot := newTestJokeBot(t, api)
+	bot.fetchEnglishJoke = func(context.Context) (Joke, error) { return Joke{Text: "English joke"}, nil }
+	bot.sendScheduledJoke(Subscription{ChatID: 301, At: "09:00", Lang: langRussian})
+	bot.sendScheduledJoke(Subscription{ChatID: 301, At: "10:00", Lang: langEnglish})
+
+	calls := api.callsTo("sendMessage")
+	if len(calls) != 2 || calls[0].Params["text"] != "Русский анекдот" || calls[1].Params["text"] != "English joke" {
+		t.Fatalf("unexpected scheduled messages: %v", calls)
+	}
+	if calls[1].Params["reply_markup"] == "" {
+		t.Error("expected translate button under English joke")
+	}
+}
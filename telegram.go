@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Режимы получения обновлений Telegram
+const (
+	telegramModeWebhook = "webhook"
+	telegramModePolling = "polling"
+)
+
+// telegramSecretHeader — заголовок, в котором Telegram передаёт секрет webhook
+const telegramSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// webhookSecretPattern — ограничения Telegram на secret_token
+var webhookSecretPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// Параметры подключения к Telegram по умолчанию
+const (
+	defaultTelegramPollTimeout = 25 * time.Second
+	defaultTelegramPollWorkers = 16
+	telegramRetryDelay         = 5 * time.Second
+	maxTelegramRetryDelay      = time.Minute
+)
+
+// TelegramConfig описывает работу Telegram-бота
+type TelegramConfig struct {
+	// Mode — webhook (по умолчанию) или polling для машин без публичного HTTPS-адреса
+	Mode        string        `yaml:"mode"`
+	PollTimeout time.Duration `yaml:"poll_timeout"`
+	// PollWorkers — сколько обновлений long polling обрабатывается одновременно
+	PollWorkers int `yaml:"poll_workers"`
+	// APIEndpoint — адрес Bot API в формате tgbotapi.APIEndpoint (например, локальный сервер Bot API)
+	APIEndpoint string `yaml:"api_endpoint"`
+
+	// WebhookURL — публичный адрес /telegram-webhook; если задан, setWebhook вызывается при запуске
+	WebhookURL string `yaml:"webhook_url"`
+	// WebhookSecret сверяется с заголовком X-Telegram-Bot-Api-Secret-Token; в режиме
+	// webhook обязателен, если не задан WebhookURL (иначе генерируется при запуске)
+	WebhookSecret string `yaml:"webhook_secret"`
+	// DeleteWebhookOnShutdown вызывает deleteWebhook при остановке сервиса
+	DeleteWebhookOnShutdown bool `yaml:"delete_webhook_on_shutdown"`
+
+	// JokeMemory — анекдоты, которые можно перевести кнопкой под сообщением
+	JokeMemory JokeMemoryConfig `yaml:"joke_memory"`
+	// Subscriptions — рассылка анекдотов по расписанию (/subscribe)
+	Subscriptions SubscriptionsConfig `yaml:"subscriptions"`
+	// RateLimit ограничивает частоту команд в чате и от одного пользователя
+	RateLimit BotRateLimitConfig `yaml:"rate_limit"`
+	// Outgoing — очередь исходящих сообщений с учётом ограничений Telegram
+	Outgoing OutgoingConfig `yaml:"outgoing"`
+}
+
+// IsPolling сообщает, что бот получает обновления через long polling
+func (c TelegramConfig) IsPolling() bool {
+	return c.Mode == telegramModePolling
+}
+
+func (c TelegramConfig) validate() error {
+	switch c.Mode {
+	case "", telegramModeWebhook, telegramModePolling:
+	default:
+		return fmt.Errorf("неизвестный режим Telegram %q, допустимы webhook и polling", c.Mode)
+	}
+	if c.PollTimeout < 0 {
+		return fmt.Errorf("telegram.poll_timeout не может быть отрицательным")
+	}
+	if c.PollWorkers < 0 {
+		return fmt.Errorf("telegram.poll_workers не может быть отрицательным")
+	}
+	if c.WebhookSecret != "" && !webhookSecretPattern.MatchString(c.WebhookSecret) {
+		return fmt.Errorf("telegram.webhook_secret: допустимы 1–256 символов A-Z, a-z, 0-9, _ и -")
+	}
+	if c.WebhookURL != "" {
+		u, err := url.Parse(c.WebhookURL)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("telegram.webhook_url должен быть абсолютным https-адресом")
+		}
+	}
+	if err := c.JokeMemory.validate(); err != nil {
+		return err
+	}
+	if err := c.Subscriptions.validate(); err != nil {
+		return err
+	}
+	if err := c.RateLimit.validate(); err != nil {
+		return err
+	}
+	return c.Outgoing.validate()
+}
+
+// ensureWebhookSecret гарантирует секрет webhook: без него /telegram-webhook принял бы
+// обновления от кого угодно. Если секрет не задан, но задан webhook_url, генерируется
+// случайный секрет и передаётся в setWebhook при запуске.
+func (c *TelegramConfig) ensureWebhookSecret() error {
+	if c.IsPolling() || c.WebhookSecret != "" {
+		return nil
+	}
+	if c.WebhookURL == "" {
+		return fmt.Errorf("в режиме webhook нужен telegram.webhook_secret (или telegram.webhook_url, чтобы сгенерировать секрет)")
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("ошибка генерации секрета webhook: %w", err)
+	}
+	c.WebhookSecret = hex.EncodeToString(secret)
+	return nil
+}
+
+// startTelegramBot один раз создаёт клиента Telegram (повторяя попытки, пока
+// API недоступен), запускает рассылку подписок и в режиме polling начинает получать обновления.
+// Возвращаемая функция останавливает бота.
+func startTelegramBot(token string, cfg TelegramConfig) func(ctx context.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	var bot *tgbotapi.BotAPI
+	var queue *OutgoingQueue
+	go func() {
+		defer close(done)
+		bot = connectTelegramBot(ctx, token, orDefault(cfg.APIEndpoint, tgbotapi.APIEndpoint))
+		if bot == nil {
+			return
+		}
+		// Чаты, заблокировавшие бота, больше не получают анекдоты по подписке
+		queue = NewOutgoingQueue(bot, cfg.Outgoing, func(chatID int64) {
+			if handler := jokeBot.Load(); handler != nil {
+				handler.forgetChat(chatID)
+			}
+		})
+		handler := NewJokeBot(queue)
+		handler.username = bot.Self.UserName
+		handler.limiter = NewCommandLimiter(cfg.RateLimit)
+		jokeBot.Store(handler)
+		// Рассылка начинается только с готовым ботом, иначе подписки, наступившие
+		// во время подключения, были бы пропущены
+		if subscriptions != nil {
+			subscriptions.Start()
+		}
+		if !cfg.IsPolling() {
+			if cfg.WebhookURL != "" {
+				registerTelegramWebhook(bot, cfg)
+			}
+			logger.Infof("Telegram-бот @%s ожидает обновления через webhook", bot.Self.UserName)
+			return
+		}
+		runTelegramPolling(ctx, bot, cfg)
+	}()
+
+	return func(shutdownCtx context.Context) {
+		cancel()
+		select {
+		case <-done:
+		case <-shutdownCtx.Done():
+			logger.Warn("Telegram-бот не успел остановиться")
+			return
+		}
+		if subscriptions != nil {
+			subscriptions.Stop()
+		}
+		if queue != nil {
+			queue.Stop(shutdownCtx)
+			stats := queue.Stats()
+			logger.Infof("Очередь сообщений Telegram: отправлено %d, повторов %d, ошибок %d, отброшено %d, заблокировавших чатов %d",
+				stats.Sent, stats.Retried, stats.Failed, stats.Dropped, stats.Blocked)
+		}
+		if bot != nil && !cfg.IsPolling() && cfg.DeleteWebhookOnShutdown {
+			if _, err := bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+				logger.Errorf("Ошибка удаления webhook: %v", err)
+			} else {
+				logger.Info("Webhook Telegram удалён")
+			}
+		}
+	}
+}
+
+// registerTelegramWebhook вызывает setWebhook с публичным адресом и секретом.
+// WebhookConfig из tgbotapi v5.5.1 не поддерживает secret_token, поэтому параметры задаются вручную.
+func registerTelegramWebhook(bot *tgbotapi.BotAPI, cfg TelegramConfig) {
+	params := tgbotapi.Params{"url": cfg.WebhookURL, "secret_token": cfg.WebhookSecret}
+	if _, err := bot.MakeRequest("setWebhook", params); err != nil {
+		logger.Errorf("Ошибка установки webhook %s: %v", cfg.WebhookURL, err)
+		return
+	}
+	logger.Infof("Webhook Telegram установлен: %s", cfg.WebhookURL)
+}
+
+// connectTelegramBot создаёт клиента, повторяя попытки с увеличивающейся паузой
+func connectTelegramBot(ctx context.Context, token, endpoint string) *tgbotapi.BotAPI {
+	delay := telegramRetryDelay
+	for {
+		bot, err := tgbotapi.NewBotAPIWithAPIEndpoint(token, endpoint)
+		if err == nil {
+			return bot
+		}
+		logger.Errorf("Ошибка запуска Telegram-бота, повтор через %s: %v", delay, err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxTelegramRetryDelay {
+			delay = maxTelegramRetryDelay
+		}
+	}
+}
+
+// runTelegramPolling получает обновления через getUpdates до отмены контекста.
+// Обновления обрабатываются параллельно, не больше telegram.poll_workers сразу,
+// чтобы медленный провайдер не задерживал остальные чаты; перед возвратом
+// функция дожидается начатых обработчиков.
+func runTelegramPolling(ctx context.Context, bot *tgbotapi.BotAPI, cfg TelegramConfig) {
+	// getUpdates не работает, пока у бота установлен webhook
+	if _, err := bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		logger.Errorf("Ошибка удаления webhook перед long polling: %v", err)
+	}
+
+	timeout := cfg.PollTimeout
+	if timeout <= 0 {
+		timeout = defaultTelegramPollTimeout
+	}
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = int(timeout.Seconds())
+	updates := bot.GetUpdatesChan(u)
+	go func() {
+		<-ctx.Done()
+		bot.StopReceivingUpdates()
+	}()
+
+	logger.Infof("Telegram-бот @%s получает обновления через long polling", bot.Self.UserName)
+	handler := jokeBot.Load()
+	workers := cfg.PollWorkers
+	if workers <= 0 {
+		workers = defaultTelegramPollWorkers
+	}
+	slots := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for update := range updates {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(update tgbotapi.Update) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			handler.processTelegramUpdate(update)
+		}(update)
+	}
+	wg.Wait()
+	logger.Info("Long polling Telegram остановлен")
+}
+
+// telegramWebhookHandler обрабатывает входящие webhook-запросы Telegram
+func telegramWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	// Без настроенного секрета запрос проверить нечем, поэтому он отклоняется
+	var secret string
+	if appConfig != nil {
+		secret = appConfig.Telegram.WebhookSecret
+	}
+	got := r.Header.Get(telegramSecretHeader)
+	if secret == "" || subtle.ConstantTimeCompare([]byte(got), []byte(secret)) != 1 {
+		logFor(r.Context()).Warnf("Отклонён webhook Telegram с неверным секретом от %s", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	bot := jokeBot.Load()
+	if bot == nil {
+		logFor(r.Context()).Error("Webhook Telegram получен, но бот ещё не инициализирован")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	update := tgbotapi.Update{}
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		logFor(r.Context()).Errorf("Ошибка декодирования webhook: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	bot.processTelegramUpdate(update)
+	w.WriteHeader(http.StatusOK)
+}
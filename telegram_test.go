@@ -0,0 +1,322 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTelegramAPI — имитация Telegram Bot API, запоминающая вызовы методов
+type fakeTelegramAPI struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	calls   []fakeTelegramCall
+	updates []json.RawMessage
+}
+
+type fakeTelegramCall struct {
+	Method string
+	Params map[string]string
+}
+
+func newFakeTelegramAPI(t *testing.T) *fakeTelegramAPI {
+	t.Helper()
+	api := &fakeTelegramAPI{}
+	api.Server = httptest.NewServer(http.HandlerFunc(api.handle))
+	t.Cleanup(api.Close)
+	return api
+}
+
+// endpoint возвращает адрес в формате tgbotapi.APIEndpoint
+func (api *fakeTelegramAPI) endpoint() string {
+	return api.URL + "/bot%s/%s"
+}
+
+func (api *fakeTelegramAPI) pushUpdate(update string) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.updates = append(api.updates, json.RawMessage(update))
+}
+
+// callsTo возвращает вызовы указанного метода
+func (api *fakeTelegramAPI) callsTo(method string) []fakeTelegramCall {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	var calls []fakeTelegramCall
+	for _, c := range api.calls {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// waitFor ждёт вызова метода
+func (api *fakeTelegramAPI) waitFor(t *testing.T, method string) []fakeTelegramCall {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if calls := api.callsTo(method); len(calls) > 0 {
+			return calls
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("method %s was not called", method)
+	return nil
+}
+
+func (api *fakeTelegramAPI) handle(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	r.ParseForm()
+	params := make(map[string]string)
+	for k := range r.Form {
+		params[k] = r.Form.Get(k)
+	}
+
+	var result string
+	switch method {
+	case "getMe":
+		result = `{"id":1,"is_bot":true,"first_name":"Joke","username":"joke_bot"}`
+	case "getUpdates":
+		api.mu.Lock()
+		updates := api.updates
+		api.updates = nil
+		api.mu.Unlock()
+		if len(updates) == 0 {
+			time.Sleep(20 * time.Millisecond)
+		}
+		data, _ := json.Marshal(updates)
+		result = string(data)
+		if len(updates) == 0 {
+			result = "[]"
+		}
+	case "sendMessage", "editMessageReplyMarkup", "editMessageText":
+		result = `{"message_id":100,"date":0,"chat":{"id":123,"type":"private"}}`
+	default:
+		result = "true"
+	}
+	if method != "getUpdates" {
+		api.mu.Lock()
+		api.calls = append(api.calls, fakeTelegramCall{Method: method, Params: params})
+		api.mu.Unlock()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"ok":true,"result":` + result + `}`))
+}
+
+func TestStartTelegramBot_Polling(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	defer jokeBot.Store(nil)
+	api.pushUpdate(`{"update_id":1,"message":{"message_id":1,"date":0,"chat":{"id":123,"type":"private"},"text":"/start","entities":[{"type":"bot_command","offset":0,"length":6}]}}`)
+
+	stop := startTelegramBot("test-token", TelegramConfig{Mode: telegramModePolling, PollTimeout: time.Second, APIEndpoint: api.endpoint()})
+	calls := api.waitFor(t, "sendMessage")
+	if calls[0].Params["chat_id"] != "123" || !strings.Contains(calls[0].Params["text"], "бот-анекдотчик") {
+		t.Errorf("unexpected sendMessage params: %v", calls[0].Params)
+	}
+	if len(api.callsTo("deleteWebhook")) != 1 {
+		t.Error("expected webhook to be deleted before polling")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stop(ctx)
+	if ctx.Err() != nil {
+		t.Error("bot did not stop in time")
+	}
+}
+
+func TestStartTelegramBot_PollingHandlesUpdatesConcurrently(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	saved := providerRegistry
+	defer func() { providerRegistry = saved; jokeBot.Store(nil) }()
+	release := make(chan struct{})
+	providerRegistry = NewProviderRegistry(ProviderSpec{
+		Provider: &stubProvider{name: "anekdot.ru", fetch: func() (Joke, error) {
+			<-release
+			return Joke{Text: "Медленный анекдот", Source: "anekdot.ru"}, nil
+		}},
+		Weight:  1,
+		Russian: true,
+	})
+	api.pushUpdate(`{"update_id":1,"message":{"message_id":1,"date":0,"chat":{"id":1,"type":"private"},"text":"/joke","entities":[{"type":"bot_command","offset":0,"length":5}]}}`)
+	api.pushUpdate(`{"update_id":2,"message":{"message_id":2,"date":0,"chat":{"id":2,"type":"private"},"text":"/start","entities":[{"type":"bot_command","offset":0,"length":6}]}}`)
+
+	stop := startTelegramBot("test-token", TelegramConfig{Mode: telegramModePolling, PollTimeout: time.Second, APIEndpoint: api.endpoint()})
+	// Медленный /joke в одном чате не задерживает ответ другому
+	calls := api.waitFor(t, "sendMessage")
+	if calls[0].Params["chat_id"] != "2" {
+		t.Errorf("expected /start reply while /joke is in flight, got %v", calls[0].Params)
+	}
+
+	// Остановка дожидается начатых обработчиков
+	stopped := make(chan struct{})
+	go func() {
+		stop(context.Background())
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("bot stopped before the in-flight /joke finished")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-stopped:
+	case <-time.After(3 * time.Second):
+		t.Fatal("bot did not stop in time")
+	}
+}
+
+func TestStartTelegramBot_StartsSubscriptionsAfterConnect(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	saved := subscriptions
+	defer func() { subscriptions = saved; jokeBot.Store(nil) }()
+	botReady := make(chan bool, 1)
+	var err error
+	subscriptions, err = NewSubscriptionScheduler(SubscriptionsConfig{}, nil, func(Subscription) {
+		botReady <- jokeBot.Load() != nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	subscriptions.tick = 10 * time.Millisecond
+	subscriptions.Subscribe(context.Background(), Subscription{ChatID: 1, At: "09:00", Timezone: "UTC"})
+	subscriptions.mu.Lock()
+	subscriptions.entries[subscriptionKey{1, "09:00"}].next = time.Now()
+	subscriptions.mu.Unlock()
+
+	stop := startTelegramBot("test-token", TelegramConfig{APIEndpoint: api.endpoint()})
+	select {
+	case ready := <-botReady:
+		if !ready {
+			t.Error("subscription delivered before the bot was connected")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("subscription was not delivered")
+	}
+	stop(context.Background())
+	subscriptions.mu.Lock()
+	defer subscriptions.mu.Unlock()
+	if subscriptions.cancel != nil {
+		t.Error("expected scheduler to stop with the bot")
+	}
+}
+
+func TestTelegramWebhookHandler_NoBot(t *testing.T) {
+	savedConfig := appConfig
+	defer func() { appConfig = savedConfig }()
+	appConfig = &Config{Telegram: TelegramConfig{WebhookSecret: "s3cret"}}
+	jokeBot.Store(nil)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/telegram-webhook", strings.NewReader(`{}`))
+	req.Header.Set(telegramSecretHeader, "s3cret")
+	telegramWebhookHandler(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 before bot initialization, got %d", w.Code)
+	}
+}
+
+func TestTelegramWebhookHandler_NoSecretConfigured(t *testing.T) {
+	savedConfig := appConfig
+	defer func() { appConfig = savedConfig }()
+	appConfig = &Config{}
+	w := httptest.NewRecorder()
+	telegramWebhookHandler(w, httptest.NewRequest("POST", "/telegram-webhook", strings.NewReader(`{}`)))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a configured secret, got %d", w.Code)
+	}
+}
+
+func TestTelegramConfig_EnsureWebhookSecret(t *testing.T) {
+	missing := TelegramConfig{Mode: telegramModeWebhook}
+	if err := missing.ensureWebhookSecret(); err == nil {
+		t.Error("expected error in webhook mode without secret and webhook_url")
+	}
+	generated := TelegramConfig{WebhookURL: "https://example.com/hook"}
+	if err := generated.ensureWebhookSecret(); err != nil || !webhookSecretPattern.MatchString(generated.WebhookSecret) {
+		t.Errorf("expected generated secret, got %q, %v", generated.WebhookSecret, err)
+	}
+	polling := TelegramConfig{Mode: telegramModePolling}
+	if err := polling.ensureWebhookSecret(); err != nil || polling.WebhookSecret != "" {
+		t.Errorf("polling mode needs no secret, got %q, %v", polling.WebhookSecret, err)
+	}
+}
+
+func TestTelegramWebhookHandler_Secret(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	savedConfig := appConfig
+	defer func() { appConfig = savedConfig; jokeBot.Store(nil) }()
+	appConfig = &Config{Telegram: TelegramConfig{WebhookSecret: "s3cret"}}
+
+	stop := startTelegramBot("test-token", TelegramConfig{APIEndpoint: api.endpoint()})
+	defer stop(context.Background())
+	for jokeBot.Load() == nil {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	update := `{"update_id":1,"message":{"message_id":1,"date":0,"chat":{"id":123,"type":"private"},"text":"/start","entities":[{"type":"bot_command","offset":0,"length":6}]}}`
+	for secret, want := range map[string]int{"": http.StatusUnauthorized, "wrong": http.StatusUnauthorized, "s3cret": http.StatusOK} {
+		req := httptest.NewRequest("POST", "/telegram-webhook", strings.NewReader(update))
+		if secret != "" {
+			req.Header.Set(telegramSecretHeader, secret)
+		}
+		w := httptest.NewRecorder()
+		telegramWebhookHandler(w, req)
+		if w.Code != want {
+			t.Errorf("secret %q: expected %d, got %d", secret, want, w.Code)
+		}
+	}
+	// Сообщения отправляются очередью асинхронно
+	if n := len(api.waitFor(t, "sendMessage")); n != 1 {
+		t.Errorf("expected only the authenticated update to be processed, got %d messages", n)
+	}
+}
+
+func TestStartTelegramBot_RegistersWebhook(t *testing.T) {
+	api := newFakeTelegramAPI(t)
+	defer jokeBot.Store(nil)
+
+	stop := startTelegramBot("test-token", TelegramConfig{
+		APIEndpoint:             api.endpoint(),
+		WebhookURL:              "https://example.com/telegram-webhook",
+		WebhookSecret:           "s3cret",
+		DeleteWebhookOnShutdown: true,
+	})
+	calls := api.waitFor(t, "setWebhook")
+	if calls[0].Params["url"] != "https://example.com/telegram-webhook" || calls[0].Params["secret_token"] != "s3cret" {
+		t.Errorf("unexpected setWebhook params: %v", calls[0].Params)
+	}
+
+	stop(context.Background())
+	if len(api.callsTo("deleteWebhook")) != 1 {
+		t.Error("expected deleteWebhook on shutdown")
+	}
+}
+
+func TestTelegramConfig_Validate(t *testing.T) {
+	invalid := []TelegramConfig{
+		{Mode: "push"},
+		{WebhookSecret: "with spaces"},
+		{WebhookURL: "http://example.com/telegram-webhook"},
+		{Mode: telegramModePolling, PollWorkers: -1},
+	}
+	for _, cfg := range invalid {
+		if err := cfg.validate(); err == nil {
+			t.Errorf("expected validation error for %+v", cfg)
+		}
+	}
+	valid := TelegramConfig{Mode: telegramModeWebhook, WebhookURL: "https://example.com/hook", WebhookSecret: "abc_DEF-123"}
+	if err := valid.validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
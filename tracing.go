@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Экспортёры трасс
+const (
+	tracingExporterNone   = "none"
+	tracingExporterOTLP   = "otlp"
+	tracingExporterStdout = "stdout"
+	tracingExporterFile   = "file"
+)
+
+// tracerName — имя инструментирования в спанах сервиса
+const tracerName = "joke-service"
+
+// untracedRoutes — служебные маршруты, запросы к которым не трассируются
+var untracedRoutes = map[string]bool{
+	"/metrics": true,
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// TracingConfig описывает трассировку OpenTelemetry
+type TracingConfig struct {
+	// Exporter — куда отправлять трассы: none (по умолчанию), otlp, stdout или file
+	Exporter string `yaml:"exporter"`
+	// Endpoint — адрес OTLP/HTTP-коллектора, например http://otel-collector:4318;
+	// если не задан, используется OTEL_EXPORTER_OTLP_ENDPOINT или localhost:4318
+	Endpoint string `yaml:"endpoint"`
+	// Headers — дополнительные заголовки запросов к коллектору (например, токен)
+	Headers map[string]string `yaml:"headers"`
+	// File — файл, в который экспортёр file дописывает трассы в JSON
+	File string `yaml:"file"`
+	// SampleRatio — доля записываемых трасс от 0 до 1 (по умолчанию 1 — все)
+	SampleRatio float64 `yaml:"sample_ratio"`
+	// ServiceName — имя сервиса в трассах (по умолчанию joke-service)
+	ServiceName string `yaml:"service_name"`
+}
+
+func (c TracingConfig) validate() error {
+	switch c.Exporter {
+	case "", tracingExporterNone, tracingExporterOTLP, tracingExporterStdout:
+	case tracingExporterFile:
+		if c.File == "" {
+			return fmt.Errorf("tracing.file обязателен для экспортёра file")
+		}
+	default:
+		return fmt.Errorf("неизвестный tracing.exporter %q: допустимы none, otlp, stdout и file", c.Exporter)
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		return fmt.Errorf("tracing.sample_ratio должен быть от 0 до 1")
+	}
+	return nil
+}
+
+// Enabled сообщает, включена ли трассировка
+func (c TracingConfig) Enabled() bool {
+	return c.Exporter != "" && c.Exporter != tracingExporterNone
+}
+
+// setupTracing настраивает глобальный провайдер трасс и возвращает функцию,
+// которая отправляет накопленные спаны и останавливает экспорт
+func setupTracing(ctx context.Context, cfg TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !cfg.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, closer, err := newSpanExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(orDefault(cfg.ServiceName, tracerName)),
+		semconv.ServiceVersion(Version),
+	))
+	if err != nil {
+		return nil, err
+	}
+	ratio := cfg.SampleRatio
+	if ratio <= 0 {
+		ratio = 1
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			if closeErr := closer.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
+
+// newSpanExporter создаёт экспортёр по настройкам; closer закрывает файл экспортёра file
+func newSpanExporter(ctx context.Context, cfg TracingConfig) (sdktrace.SpanExporter, io.Closer, error) {
+	switch cfg.Exporter {
+	case tracingExporterOTLP:
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+		}
+		exporter, err := otlptracehttp.New(ctx, opts...)
+		return exporter, nil, err
+	case tracingExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		return exporter, nil, err
+	case tracingExporterFile:
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ошибка открытия файла трасс: %w", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return exporter, f, nil
+	}
+	return nil, nil, fmt.Errorf("неизвестный tracing.exporter %q", cfg.Exporter)
+}
+
+// startSpan начинает спан сервиса. Трассировщик берётся из глобального
+// провайдера при каждом вызове, чтобы учитывать его замену (в том числе в тестах).
+func startSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, opts...)
+}
+
+// endSpan отмечает ошибку в спане, если она есть, и завершает его
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracingMiddleware создаёт серверный спан на каждый HTTP-запрос, продолжая
+// трассу клиента из заголовка traceparent. Подключается после requestIDMiddleware,
+// чтобы ID запроса попал в атрибуты спана.
+func tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+		if untracedRoutes[route] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := startSpan(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(r.URL.Path),
+				attribute.String("request.id", requestIDFromContext(r.Context())),
+			))
+		defer span.End()
+		rw := &responseWriter{w, http.StatusOK}
+		next.ServeHTTP(rw, r.WithContext(ctx))
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rw.statusCode))
+		if rw.statusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rw.statusCode))
+		}
+	})
+}
+
+// tracingTransport создаёт клиентский спан на каждый исходящий HTTP-запрос.
+// Контекст трассы передаётся в заголовках только запросам, разрешившим это через
+// withTracePropagation: сторонним сайтам идентификаторы трасс не отправляются.
+// Строка запроса в спан не попадает: в ней бывает текст анекдота (например, у Google Translate).
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func (t tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := startSpan(req.Context(), "HTTP "+req.Method+" "+req.URL.Host,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.ServerAddress(req.URL.Hostname()),
+			semconv.URLPath(req.URL.Path),
+			semconv.HTTPRequestResendCount(resendCountFromContext(req.Context())),
+		))
+	req = req.Clone(ctx)
+	if propagate, _ := ctx.Value(tracePropagationKey{}).(bool); propagate {
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		endSpan(span, err)
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+	span.End()
+	return resp, nil
+}
+
+type tracePropagationKey struct{}
+
+// withTracePropagation разрешает передать контекст трассы (traceparent) в запросе
+// с контекстом ctx; используется только для внутренних сервисов
+func withTracePropagation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tracePropagationKey{}, true)
+}
+
+// telegramRequestName возвращает имя запроса Telegram для спанов, например MessageConfig
+func telegramRequestName(c any) string {
+	name := fmt.Sprintf("%T", c)
+	return strings.TrimPrefix(name, "tgbotapi.")
+}
@@ -0,0 +1,227 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans подменяет глобальный провайдер трасс на записывающий до конца теста
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	old, oldPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(old)
+		otel.SetTextMapPropagator(oldPropagator)
+	})
+	return recorder
+}
+
+// spanByName возвращает первый завершённый спан с именем name
+func spanByName(t *testing.T, recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	var names []string
+	for _, span := range recorder.Ended() {
+		if span.Name() == name {
+			return span
+		}
+		names = append(names, span.Name())
+	}
+	t.Fatalf("span %q not found among %q", name, names)
+	return nil
+}
+
+// spanAttr возвращает значение атрибута спана
+func spanAttr(span sdktrace.ReadOnlySpan, key string) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracingConfig_Validate(t *testing.T) {
+	valid := []TracingConfig{{}, {Exporter: "none"}, {Exporter: "otlp", SampleRatio: 0.5}, {Exporter: "file", File: "traces.json"}}
+	for _, cfg := range valid {
+		if err := cfg.validate(); err != nil {
+			t.Errorf("validate(%+v) = %v, want nil", cfg, err)
+		}
+	}
+	invalid := []TracingConfig{{Exporter: "jaeger"}, {Exporter: "file"}, {Exporter: "stdout", SampleRatio: 1.5}}
+	for _, cfg := range invalid {
+		if err := cfg.validate(); err == nil {
+			t.Errorf("validate(%+v) = nil, want error", cfg)
+		}
+	}
+}
+
+func TestTracing_RandomJokeSpans(t *testing.T) {
+	recorder := recordSpans(t)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("traceparent") != "" {
+			t.Error("trace context leaked to a third-party provider")
+		}
+		w.Write([]byte(`<meta name="description" content="Анекдот">`))
+	}))
+	defer upstream.Close()
+
+	registry := NewProviderRegistry(ProviderSpec{Provider: BaneksProvider{BaseURL: upstream.URL}, Weight: 1, Russian: true})
+	router := mux.NewRouter()
+	router.Use(requestIDMiddleware)
+	router.Use(tracingMiddleware)
+	router.HandleFunc("/random-joke", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := registry.FetchJoke(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+	req := httptest.NewRequest("GET", "/random-joke", nil)
+	req.Header.Set(requestIDHeader, "req-7")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	server := spanByName(t, recorder, "GET /random-joke")
+	if got := spanAttr(server, "http.response.status_code").AsInt64(); got != http.StatusOK {
+		t.Errorf("server status = %d, want 200", got)
+	}
+	if got := spanAttr(server, "request.id").AsString(); got != "req-7" {
+		t.Errorf("request.id = %q, want req-7", got)
+	}
+	selection := spanByName(t, recorder, "providers.select")
+	if selection.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Error("providers.select is not a child of the server span")
+	}
+	if got := spanAttr(selection, "providers.attempts").AsInt64(); got != 1 {
+		t.Errorf("providers.attempts = %d, want 1", got)
+	}
+	fetch := spanByName(t, recorder, "provider.FetchJoke")
+	if got := spanAttr(fetch, "joke.provider").AsString(); got != "baneks.ru" {
+		t.Errorf("joke.provider = %q, want baneks.ru", got)
+	}
+	client := spanByName(t, recorder, "HTTP GET "+strings.TrimPrefix(upstream.URL, "http://"))
+	if client.Parent().SpanID() != fetch.SpanContext().SpanID() {
+		t.Error("HTTP client span is not a child of provider.FetchJoke")
+	}
+	if got := spanAttr(client, "http.response.status_code").AsInt64(); got != http.StatusOK {
+		t.Errorf("client status = %d, want 200", got)
+	}
+}
+
+func TestTracing_PropagatesOnlyToInternalServices(t *testing.T) {
+	recordSpans(t)
+	var mu sync.Mutex
+	propagated := make(map[string]bool)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		propagated[r.URL.Path] = r.Header.Get("traceparent") != ""
+		mu.Unlock()
+		w.Write([]byte(`{}`))
+	}))
+	defer upstream.Close()
+
+	ctx, span := startSpan(context.Background(), "test")
+	defer span.End()
+	JokeAPIProvider{BaseURL: upstream.URL}.FetchJoke(ctx)
+	LibreTranslator{BaseURL: upstream.URL}.Translate(ctx, "joke", "en", "ru")
+	GoogleTranslator{BaseURL: upstream.URL}.Translate(ctx, "joke", "en", "ru")
+
+	want := map[string]bool{"/joke/Any": true, "/translate": true, "/translate_a/single": false}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(propagated, want) {
+		t.Errorf("traceparent sent = %v, want %v", propagated, want)
+	}
+}
+
+func TestTracing_UntracedRoutes(t *testing.T) {
+	recorder := recordSpans(t)
+	router := mux.NewRouter()
+	router.Use(tracingMiddleware)
+	router.HandleFunc("/healthz", healthzHandler)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+	if spans := recorder.Ended(); len(spans) != 0 {
+		t.Errorf("spans = %d, want none for /healthz", len(spans))
+	}
+}
+
+func TestTracing_OutgoingRetries(t *testing.T) {
+	recorder := recordSpans(t)
+	tooMany := &tgbotapi.Error{Code: http.StatusTooManyRequests, ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 1}}
+	sender := &stubSender{errs: map[int64][]error{1: {tooMany, tooMany}}}
+	q, _ := newTestOutgoingQueue(sender, OutgoingConfig{GlobalRate: 1000}, nil)
+	q.Send(tgbotapi.NewMessage(1, "анекдот"))
+	q.wg.Wait()
+
+	span := spanByName(t, recorder, "telegram.Send")
+	if got := spanAttr(span, "telegram.retries").AsInt64(); got != 2 {
+		t.Errorf("telegram.retries = %d, want 2", got)
+	}
+	if got := spanAttr(span, "telegram.request").AsString(); got != "MessageConfig" {
+		t.Errorf("telegram.request = %q, want MessageConfig", got)
+	}
+}
+
+func TestTracing_TranslationCacheHit(t *testing.T) {
+	recorder := recordSpans(t)
+	translator := NewCachingTranslator(NewMeteredTranslator(DictionaryTranslator{}), 10, time.Hour, nil)
+	for i := 0; i < 2; i++ {
+		translator.Translate(context.Background(), "hello", "en", "ru")
+	}
+	var hits, backend int
+	for _, span := range recorder.Ended() {
+		switch span.Name() {
+		case "translation.Translate":
+			if spanAttr(span, "translation.cache_hit").AsBool() {
+				hits++
+			}
+		case "translation.backend":
+			backend++
+		}
+	}
+	if hits != 1 || backend != 1 {
+		t.Errorf("cache hits = %d, backend spans = %d, want 1 and 1", hits, backend)
+	}
+}
+
+func TestSetupTracing_FileExporter(t *testing.T) {
+	old := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(old)
+
+	path := filepath.Join(t.TempDir(), "traces.json")
+	shutdown, err := setupTracing(context.Background(), TracingConfig{Exporter: "file", File: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := startSpan(context.Background(), "test.span")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"Name":"test.span"`) {
+		t.Errorf("trace file = %s, want test.span", data)
+	}
+}
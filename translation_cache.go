@@ -0,0 +1,226 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Параметры кэша переводов по умолчанию
+const (
+	defaultTranslationCacheSize = 1000
+	defaultTranslationCacheTTL  = 7 * 24 * time.Hour
+
+	// translationCachePruneEvery — через сколько сохранений из хранилища удаляются устаревшие переводы
+	translationCachePruneEvery = 500
+)
+
+// TranslationCacheConfig описывает кэш переводов
+type TranslationCacheConfig struct {
+	// Size — максимальное число переводов в памяти, 0 — кэш отключён
+	Size *int          `yaml:"size"`
+	TTL  time.Duration `yaml:"ttl"`
+	// Persist сохраняет переводы в хранилище SQLite (нужен storage.path)
+	Persist bool `yaml:"persist"`
+}
+
+// translationStore — постоянное хранилище переводов
+type translationStore interface {
+	LoadTranslation(ctx context.Context, key string, notBefore time.Time) (string, bool, error)
+	SaveTranslation(ctx context.Context, key, translation string) error
+	PruneTranslations(ctx context.Context, notBefore time.Time) error
+}
+
+// translationKey возвращает хэш текста и пары языков
+func translationKey(text, source, target string) string {
+	sum := sha256.Sum256([]byte(source + "\x00" + target + "\x00" + text))
+	return hex.EncodeToString(sum[:])
+}
+
+type cachedTranslation struct {
+	key         string
+	translation string
+	storedAt    time.Time
+}
+
+// CachingTranslator — LRU-кэш с TTL перед другим переводчиком
+type CachingTranslator struct {
+	inner Translator
+	size  int
+	ttl   time.Duration
+	store translationStore
+	now   func() time.Time
+	// saves считает сохранения в store, чтобы чистить его раз в translationCachePruneEvery вставок
+	saves atomic.Int64
+
+	mu    sync.Mutex
+	order *list.List
+	items map[string]*list.Element
+}
+
+// NewCachingTranslator создаёт кэш на size записей; store может быть nil
+func NewCachingTranslator(inner Translator, size int, ttl time.Duration, store translationStore) *CachingTranslator {
+	return &CachingTranslator{
+		inner: inner,
+		size:  size,
+		ttl:   ttl,
+		store: store,
+		now:   time.Now,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// newTranslationCacheFromConfig оборачивает переводчик кэшем по настройкам
+func newTranslationCacheFromConfig(inner Translator, cfg TranslationCacheConfig, storage *Storage) Translator {
+	size := defaultTranslationCacheSize
+	if cfg.Size != nil {
+		size = *cfg.Size
+	}
+	if size <= 0 {
+		return inner
+	}
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = defaultTranslationCacheTTL
+	}
+	var store translationStore
+	if cfg.Persist {
+		if storage != nil {
+			store = storage
+		} else {
+			logger.Warn("translation.cache.persist включён, но storage.path не задан: переводы кэшируются только в памяти")
+		}
+	}
+	return NewCachingTranslator(inner, size, ttl, store)
+}
+
+func (t *CachingTranslator) Name() string {
+	return t.inner.Name()
+}
+
+func (t *CachingTranslator) Translate(ctx context.Context, text, source, target string) (string, error) {
+	ctx, span := startSpan(ctx, "translation.Translate", trace.WithAttributes(
+		attribute.String("translation.backend", t.Name()),
+		attribute.String("translation.source", source),
+		attribute.String("translation.target", target),
+	))
+	translation, err := t.translate(ctx, text, source, target)
+	endSpan(span, err)
+	return translation, err
+}
+
+// translate ищет перевод в памяти и хранилище, а при промахе обращается к бэкенду
+func (t *CachingTranslator) translate(ctx context.Context, text, source, target string) (string, error) {
+	span := trace.SpanFromContext(ctx)
+	key := translationKey(text, source, target)
+	if translation, ok := t.get(key); ok {
+		observeCache("translation", true)
+		span.SetAttributes(attribute.Bool("translation.cache_hit", true))
+		return translation, nil
+	}
+	if t.store != nil {
+		translation, ok, err := t.store.LoadTranslation(ctx, key, t.now().Add(-t.ttl))
+		if err != nil {
+			logFor(ctx).Errorf("Ошибка чтения перевода из хранилища: %v", err)
+		} else if ok {
+			observeCache("translation", true)
+			span.SetAttributes(attribute.Bool("translation.cache_hit", true))
+			t.put(key, translation)
+			return translation, nil
+		}
+	}
+	observeCache("translation", false)
+	span.SetAttributes(attribute.Bool("translation.cache_hit", false))
+
+	translation, err := t.inner.Translate(ctx, text, source, target)
+	if err != nil {
+		return "", err
+	}
+	t.put(key, translation)
+	if t.store != nil {
+		storeCtx := context.WithoutCancel(ctx)
+		if err := t.store.SaveTranslation(storeCtx, key, translation); err != nil {
+			logFor(ctx).Errorf("Ошибка сохранения перевода в хранилище: %v", err)
+		}
+		// Первое сохранение тоже чистит хранилище: там могли остаться записи с прошлого запуска
+		if t.saves.Add(1)%translationCachePruneEvery == 1 {
+			if err := t.store.PruneTranslations(storeCtx, t.now().Add(-t.ttl)); err != nil {
+				logFor(ctx).Errorf("Ошибка очистки переводов в хранилище: %v", err)
+			}
+		}
+	}
+	return translation, nil
+}
+
+func (t *CachingTranslator) get(key string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	el, ok := t.items[key]
+	if !ok {
+		return "", false
+	}
+	item := el.Value.(*cachedTranslation)
+	if t.now().Sub(item.storedAt) > t.ttl {
+		t.order.Remove(el)
+		delete(t.items, key)
+		return "", false
+	}
+	t.order.MoveToFront(el)
+	return item.translation, true
+}
+
+func (t *CachingTranslator) put(key, translation string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if el, ok := t.items[key]; ok {
+		el.Value = &cachedTranslation{key: key, translation: translation, storedAt: t.now()}
+		t.order.MoveToFront(el)
+		return
+	}
+	t.items[key] = t.order.PushFront(&cachedTranslation{key: key, translation: translation, storedAt: t.now()})
+	for t.order.Len() > t.size {
+		oldest := t.order.Back()
+		t.order.Remove(oldest)
+		delete(t.items, oldest.Value.(*cachedTranslation).key)
+	}
+}
+
+// LoadTranslation возвращает сохранённый перевод, если он не старше notBefore
+func (s *Storage) LoadTranslation(ctx context.Context, key string, notBefore time.Time) (string, bool, error) {
+	var translation string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT translation FROM translations WHERE key = ? AND created_at >= ?`,
+		key, notBefore.Unix(),
+	).Scan(&translation)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return translation, true, nil
+}
+
+// SaveTranslation сохраняет перевод
+func (s *Storage) SaveTranslation(ctx context.Context, key, translation string) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO translations (key, translation, created_at) VALUES (?, ?, ?)`,
+		key, translation, time.Now().Unix())
+	return err
+}
+
+// PruneTranslations удаляет переводы, сохранённые раньше notBefore
+func (s *Storage) PruneTranslations(ctx context.Context, notBefore time.Time) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM translations WHERE created_at < ?`, notBefore.Unix())
+	return err
+}
@@ -0,0 +1,130 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// countingTranslator считает обращения к бэкенду перевода
+type countingTranslator struct {
+	calls int
+}
+
+func (t *countingTranslator) Name() string { return "counting" }
+
+func (t *countingTranslator) Translate(ctx context.Context, text, source, target string) (string, error) {
+	t.calls++
+	return target + ":" + text, nil
+}
+
+func TestCachingTranslator_HitsAndMisses(t *testing.T) {
+	inner := &countingTranslator{}
+	cache := NewCachingTranslator(inner, 2, time.Hour, nil)
+	ctx := context.Background()
+	hits, misses := cacheRequestsTotal.WithLabelValues("translation", "hit"), cacheRequestsTotal.WithLabelValues("translation", "miss")
+	hitsBefore, missesBefore := testutil.ToFloat64(hits), testutil.ToFloat64(misses)
+
+	cache.Translate(ctx, "joke", "en", "ru")
+	if got, _ := cache.Translate(ctx, "joke", "en", "ru"); got != "ru:joke" {
+		t.Fatalf("unexpected cached translation %q", got)
+	}
+	// Другая пара языков — отдельная запись
+	cache.Translate(ctx, "joke", "en", "de")
+	if inner.calls != 2 {
+		t.Fatalf("expected 2 backend calls, got %d", inner.calls)
+	}
+	if h, m := testutil.ToFloat64(hits)-hitsBefore, testutil.ToFloat64(misses)-missesBefore; h != 1 || m != 2 {
+		t.Errorf("cache hits = %v, misses = %v; want 1 and 2", h, m)
+	}
+
+	// Вытеснение самой давней записи
+	cache.Translate(ctx, "another", "en", "ru")
+	cache.Translate(ctx, "joke", "en", "ru")
+	if inner.calls != 4 {
+		t.Errorf("expected evicted entry to be translated again, got %d calls", inner.calls)
+	}
+}
+
+func TestCachingTranslator_TTL(t *testing.T) {
+	inner := &countingTranslator{}
+	cache := NewCachingTranslator(inner, 10, time.Minute, nil)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	cache.Translate(ctx, "joke", "en", "ru")
+	now = now.Add(2 * time.Minute)
+	cache.Translate(ctx, "joke", "en", "ru")
+	if inner.calls != 2 {
+		t.Errorf("expected expired entry to be translated again, got %d calls", inner.calls)
+	}
+}
+
+func TestCachingTranslator_Persistent(t *testing.T) {
+	storage := openTestStorage(t)
+	ctx := context.Background()
+
+	first := &countingTranslator{}
+	NewCachingTranslator(first, 10, time.Hour, storage).Translate(ctx, "joke", "en", "ru")
+
+	// Новый кэш (например, после перезапуска) берёт перевод из хранилища
+	second := &countingTranslator{}
+	got, err := NewCachingTranslator(second, 10, time.Hour, storage).Translate(ctx, "joke", "en", "ru")
+	if err != nil || got != "ru:joke" {
+		t.Fatalf("unexpected translation %q, %v", got, err)
+	}
+	if second.calls != 0 {
+		t.Errorf("expected persisted translation to be used, got %d backend calls", second.calls)
+	}
+}
+
+// countingTranslationStore считает сохранения и очистки хранилища переводов
+type countingTranslationStore struct {
+	saves, prunes int
+}
+
+func (s *countingTranslationStore) LoadTranslation(context.Context, string, time.Time) (string, bool, error) {
+	return "", false, nil
+}
+
+func (s *countingTranslationStore) SaveTranslation(context.Context, string, string) error {
+	s.saves++
+	return nil
+}
+
+func (s *countingTranslationStore) PruneTranslations(context.Context, time.Time) error {
+	s.prunes++
+	return nil
+}
+
+func TestCachingTranslator_PrunesStorePeriodically(t *testing.T) {
+	store := &countingTranslationStore{}
+	cache := NewCachingTranslator(&countingTranslator{}, 10, time.Hour, store)
+	for i := 0; i < translationCachePruneEvery+1; i++ {
+		cache.Translate(context.Background(), fmt.Sprintf("joke %d", i), "en", "ru")
+	}
+	if store.saves != translationCachePruneEvery+1 || store.prunes != 2 {
+		t.Errorf("saves = %d, prunes = %d; want %d and 2", store.saves, store.prunes, translationCachePruneEvery+1)
+	}
+}
+
+func TestStorage_PruneTranslations(t *testing.T) {
+	storage := openTestStorage(t)
+	ctx := context.Background()
+	if err := storage.SaveTranslation(ctx, "old", "старый перевод"); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.PruneTranslations(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := storage.LoadTranslation(ctx, "old", time.Time{}); ok {
+		t.Error("expected the outdated translation to be pruned")
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+)
+
+// Адреса сервисов перевода по умолчанию
+const (
+	googleTranslateBaseURL = "https://translate.googleapis.com"
+	libreTranslateBaseURL  = "http://localhost:5000"
+)
+
+// Бэкенды перевода, доступные в config.yaml
+const (
+	translatorGoogle     = "google"
+	translatorLibre      = "libretranslate"
+	translatorDictionary = "dictionary"
+)
+
+// langCodePattern — допустимый код языка ("en", "ru", "zh-CN") или "auto"
+var langCodePattern = regexp.MustCompile(`^(auto|[a-z]{2,3}(-[A-Za-z]{2,4})?)$`)
+
+// TranslationConfig описывает бэкенд перевода
+type TranslationConfig struct {
+	Backend    string                 `yaml:"backend"`
+	BaseURL    string                 `yaml:"base_url"`
+	APIKey     string                 `yaml:"api_key"`
+	Dictionary map[string]string      `yaml:"dictionary"`
+	Cache      TranslationCacheConfig `yaml:"cache"`
+}
+
+// Translator переводит текст с языка source на язык target
+type Translator interface {
+	Name() string
+	Translate(ctx context.Context, text, source, target string) (string, error)
+}
+
+// newTranslatorFromConfig создаёт переводчик по настройкам (по умолчанию Google);
+// client может быть nil — тогда используется общий клиент
+func newTranslatorFromConfig(cfg TranslationConfig, client *http.Client) (Translator, error) {
+	switch cfg.Backend {
+	case "", translatorGoogle:
+		return GoogleTranslator{BaseURL: cfg.BaseURL, Client: client}, nil
+	case translatorLibre:
+		return LibreTranslator{BaseURL: cfg.BaseURL, APIKey: cfg.APIKey, Client: client}, nil
+	case translatorDictionary:
+		return DictionaryTranslator{Entries: cfg.Dictionary}, nil
+	default:
+		return nil, fmt.Errorf("неизвестный бэкенд перевода %q", cfg.Backend)
+	}
+}
+
+// validLangCode проверяет код языка
+func validLangCode(code string) bool {
+	return langCodePattern.MatchString(code)
+}
+
+// GoogleTranslator использует неофициальный endpoint Google Translate (client=gtx)
+type GoogleTranslator struct {
+	BaseURL string
+	Client  *http.Client // nil — общий клиент upstreamClient
+}
+
+func (t GoogleTranslator) Name() string {
+	return translatorGoogle
+}
+
+func (t GoogleTranslator) Translate(ctx context.Context, text, source, target string) (string, error) {
+	query := url.Values{
+		"client": {"gtx"},
+		"sl":     {source},
+		"tl":     {target},
+		"dt":     {"t"},
+		"q":      {text},
+	}
+	endpoint := orDefault(t.BaseURL, googleTranslateBaseURL) + "/translate_a/single?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	client := httpClientOr(t.Client)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Google Translate вернул статус %d", resp.StatusCode)
+	}
+	var data interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return "", err
+	}
+	// Ответ имеет вид [[["перевод", "оригинал", ...], ...], ...]
+	translation := ""
+	if arr, ok := data.([]interface{}); ok && len(arr) > 0 {
+		if innerArr, ok := arr[0].([]interface{}); ok {
+			for _, seg := range innerArr {
+				if segArr, ok := seg.([]interface{}); ok && len(segArr) > 0 {
+					if str, ok := segArr[0].(string); ok {
+						translation += str
+					}
+				}
+			}
+		}
+	}
+	if translation == "" {
+		return "", fmt.Errorf("Google Translate вернул пустой перевод")
+	}
+	return translation, nil
+}
+
+// LibreTranslator использует LibreTranslate (можно развернуть локально)
+type LibreTranslator struct {
+	BaseURL string
+	APIKey  string
+	Client  *http.Client // nil — общий клиент upstreamClient
+}
+
+func (t LibreTranslator) Name() string {
+	return translatorLibre
+}
+
+func (t LibreTranslator) Translate(ctx context.Context, text, source, target string) (string, error) {
+	payload, err := json.Marshal(map[string]string{
+		"q":       text,
+		"source":  source,
+		"target":  target,
+		"format":  "text",
+		"api_key": t.APIKey,
+	})
+	if err != nil {
+		return "", err
+	}
+	endpoint := orDefault(t.BaseURL, libreTranslateBaseURL) + "/translate"
+	// Перевод ничего не меняет на сервере, поэтому POST можно повторять.
+	// LibreTranslate разворачивается рядом с сервисом, ему передаётся контекст трассы.
+	req, err := http.NewRequestWithContext(withTracePropagation(withRetryableRequest(ctx)), "POST", endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := httpClientOr(t.Client)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		TranslatedText string `json:"translatedText"`
+		Error          string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("ошибка разбора ответа LibreTranslate (статус %d): %v", resp.StatusCode, err)
+	}
+	if result.Error != "" {
+		return "", fmt.Errorf("LibreTranslate: %s", result.Error)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("LibreTranslate вернул статус %d", resp.StatusCode)
+	}
+	if result.TranslatedText == "" {
+		return "", fmt.Errorf("LibreTranslate вернул пустой перевод")
+	}
+	return result.TranslatedText, nil
+}
+
+// DictionaryTranslator — заглушка для разработки и тестов: переводит только
+// известные фразы из словаря, остальной текст возвращает без изменений
+type DictionaryTranslator struct {
+	Entries map[string]string
+}
+
+func (t DictionaryTranslator) Name() string {
+	return translatorDictionary
+}
+
+func (t DictionaryTranslator) Translate(ctx context.Context, text, source, target string) (string, error) {
+	if translation, ok := t.Entries[text]; ok {
+		return translation, nil
+	}
+	return text, nil
+}
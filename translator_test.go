@@ -0,0 +1,96 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGoogleTranslator_Translate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("sl") != "ru" || q.Get("tl") != "en" || q.Get("q") != "Привет, мир!" {
+			t.Errorf("unexpected query: %v", q)
+		}
+		w.Write([]byte(`[[["Hello, ","Привет, ",null],["world!","мир!",null]],null,"ru"]`))
+	}))
+	defer server.Close()
+
+	translation, err := GoogleTranslator{BaseURL: server.URL}.Translate(context.Background(), "Привет, мир!", "ru", "en")
+	if err != nil {
+		t.Fatalf("Translate error: %v", err)
+	}
+	if translation != "Hello, world!" {
+		t.Errorf("unexpected translation %q", translation)
+	}
+}
+
+func TestLibreTranslator_Translate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.URL.Path != "/translate" || req["source"] != "en" || req["target"] != "ru" || req["api_key"] != "secret" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, req)
+		}
+		if req["q"] == "fail" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"bad request"}`))
+			return
+		}
+		w.Write([]byte(`{"translatedText":"Я люблю анекдоты!"}`))
+	}))
+	defer server.Close()
+
+	tr := LibreTranslator{BaseURL: server.URL, APIKey: "secret"}
+	translation, err := tr.Translate(context.Background(), "I like jokes!", "en", "ru")
+	if err != nil {
+		t.Fatalf("Translate error: %v", err)
+	}
+	if translation != "Я люблю анекдоты!" {
+		t.Errorf("unexpected translation %q", translation)
+	}
+	if _, err := tr.Translate(context.Background(), "fail", "en", "ru"); err == nil {
+		t.Error("expected LibreTranslate error to be returned")
+	}
+}
+
+func TestNewTranslatorFromConfig(t *testing.T) {
+	for backend, want := range map[string]string{"": "google", "libretranslate": "libretranslate", "dictionary": "dictionary"} {
+		tr, err := newTranslatorFromConfig(TranslationConfig{Backend: backend}, nil)
+		if err != nil || tr.Name() != want {
+			t.Errorf("backend %q: expected %s, got %v, %v", backend, want, tr, err)
+		}
+	}
+	if _, err := newTranslatorFromConfig(TranslationConfig{Backend: "yandex"}, nil); err == nil {
+		t.Error("expected error for unknown backend")
+	}
+}
+
+func TestTranslateHandler_Languages(t *testing.T) {
+	saved := translator
+	defer func() { translator = saved }()
+	translator = DictionaryTranslator{Entries: map[string]string{"Штирлиц": "Stirlitz"}}
+
+	body := bytes.NewBufferString(`{"text":"Штирлиц","source":"ru","target":"en"}`)
+	w := httptest.NewRecorder()
+	translateHandler(w, httptest.NewRequest("POST", "/translate", body))
+	var result struct {
+		Translation string `json:"translation"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil || result.Translation != "Stirlitz" {
+		t.Errorf("unexpected translation %q, %v", result.Translation, err)
+	}
+
+	body = bytes.NewBufferString(`{"text":"Штирлиц","target":"../etc"}`)
+	w = httptest.NewRecorder()
+	translateHandler(w, httptest.NewRequest("POST", "/translate", body))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid language, got %d", w.Code)
+	}
+}